@@ -1,14 +1,44 @@
 package v1
 
+import (
+	"time"
+
+	"github.com/awwithro/makemea/randomtable"
+)
+
 type ListTableResponse struct {
 	Tables []string `json:"tables"`
 }
 
 type GetItemResponse struct {
-	Item string `json:"item"`
+	Item  string             `json:"item"`
+	Trace *randomtable.Trace `json:"trace,omitempty"`
 }
 
 type RollResponse struct {
 	Result      int    `json:"result"`
 	Description string `json:"description"`
 }
+
+type DescribeResponse struct {
+	Table randomtable.Description `json:"table"`
+}
+
+type GetRowResponse struct {
+	Row     map[string]string `json:"row"`
+	Columns []string          `json:"columns"`
+}
+
+// ErrorResponse is returned with any status other than 200. Kind is one of badRequest,
+// notFound, limit, timeout, table or render.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+}
+
+type StatusResponse struct {
+	Version      int                       `json:"version"`
+	Hash         string                    `json:"hash"`
+	LoadedAt     time.Time                 `json:"loadedAt"`
+	FailedReload *randomtable.FailedReload `json:"failedReload,omitempty"`
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// GraphFormat is the format the dependency graph is printed in
+var GraphFormat string
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Prints the tables and the tables they use as a graph",
+	Long: `Prints every table and the tables it uses through templates and links.
+The graph can be printed in the Graphviz DOT language or as JSON.
+Tables that can't be found are drawn in red.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tree := MustGetTree()
+		graph := tree.Graph()
+		switch GraphFormat {
+		case "dot":
+			fmt.Print(graph.DOT())
+		case "json":
+			out, err := graph.JSON()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Print(out)
+		default:
+			log.Fatalf("unknown format %q, use dot or json", GraphFormat)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	graphCmd.PersistentFlags().StringVarP(&GraphFormat, "format", "f", "dot", "format of the graph, dot or json")
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/awwithro/makemea/randomtable"
+	"github.com/spf13/cobra"
+)
+
+// LintFormat is the format issues are printed in
+var LintFormat string
+
+// FailOn is the lowest severity that makes lint exit with an error
+var FailOn string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks every table for problems",
+	Long: `Checks every table for problems such as rolls that can't be made, lookups
+of tables that don't exist and templates that don't parse. Issues are printed
+as text or JSON. The command exits with an error when any issue is at least
+as severe as --fail-on.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		threshold, err := randomtable.ParseSeverity(FailOn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tree := MustGetTree()
+		issues := tree.Validate()
+		switch LintFormat {
+		case "text":
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+			fmt.Printf("%d issues found\n", len(issues))
+		case "json":
+			out, err := json.MarshalIndent(issues, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			log.Fatalf("unknown format %q, use text or json", LintFormat)
+		}
+		if randomtable.MaxSeverity(issues) >= threshold {
+			os.Exit(1)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	lintCmd.PersistentFlags().StringVarP(&LintFormat, "format", "f", "text", "format of the issues, text or json")
+	lintCmd.PersistentFlags().StringVar(&FailOn, "fail-on", "error", "lowest severity that fails the lint: info, warning or error")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/awwithro/makemea/randomtable"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// Samples is the number of times to render templates that can't be worked out exactly
+var Samples int
+
+func odds(tree randomtable.Tree, tableName string) {
+	dist, err := tree.DistributionWithSamples(tableName, Samples)
+	if err != nil {
+		log.Fatal(err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetAutoFormatHeaders(false)
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Result", "Chance"})
+	for _, o := range dist.Odds {
+		result := o.Result
+		if o.NoRow {
+			result = "(no row)"
+		} else if result == "" {
+			result = "(nothing)"
+		}
+		table.Append([]string{result, fmt.Sprintf("%.2f%%", o.Chance*100)})
+	}
+	table.Render()
+	if !dist.Exact {
+		fmt.Printf("\nSome odds were estimated from %v samples\n", dist.Samples)
+	}
+}
+
+var oddsCmd = &cobra.Command{
+	Use:   "odds [table]",
+	Short: "Prints the chance of each result from a table",
+	Run: func(cmd *cobra.Command, args []string) {
+		tree := MustGetTree()
+		tree.ValidateTables()
+		odds(tree, args[0])
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("No Table specified to show odds for")
+		}
+		return nil
+	},
+}
+
+func init() {
+	oddsCmd.PersistentFlags().IntVarP(&Samples, "samples", "s", randomtable.DEFAULT_SAMPLES, "Number of times to render templates that can't be worked out exactly")
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awwithro/makemea/randomtable"
+)
+
+// reloadDelay is how long files must stop changing before tables are reloaded
+const reloadDelay = 500 * time.Millisecond
+
+// reloader keeps a shared tree up to date with the markdown files on disk. Only the files
+// that changed are parsed again.
+type reloader struct {
+	shared *randomtable.SharedTree
+	// prepare is applied to every tree before it is served
+	prepare func(randomtable.Tree) randomtable.Tree
+}
+
+// watch reloads tables as paths change. Changes are collected until none have been
+// seen for reloadDelay so that saving many files at once only reloads once.
+func (r *reloader) watch(changes <-chan string) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	for {
+		select {
+		case path, ok := <-changes:
+			if !ok {
+				return
+			}
+			pending[path] = true
+			timer.Reset(reloadDelay)
+		case <-timer.C:
+			paths := []string{}
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			pending = map[string]bool{}
+			r.reload(paths)
+		}
+	}
+}
+
+func (r *reloader) reload(paths []string) {
+	old := r.shared.Load()
+	files := markdownFiles(old.Files(), paths)
+	if len(files) == 0 {
+		return
+	}
+	tree, err := r.load(old, files)
+	if err != nil {
+		log.Printf("Unable to reload tables, keeping the current tables: %v", err)
+		r.shared.Reject(err, nil)
+		return
+	}
+	tree = r.prepare(tree)
+	issues := tree.ValidateTables()
+	if errs := errorIssues(issues); len(errs) > 0 {
+		log.Printf("Reloaded tables have %d errors, keeping the current tables", len(errs))
+		r.shared.Reject(nil, errs)
+		return
+	}
+	changes := old.Changes(&tree)
+	log.Printf("Reloaded %s", strings.Join(files, ", "))
+	logTables("Added", changes.Added)
+	logTables("Removed", changes.Removed)
+	logTables("Changed", changes.Changed)
+	// Requests that already started keep the tree they loaded
+	r.shared.Store(tree)
+}
+
+// load parses the files again and keeps the tables from every other file
+func (r *reloader) load(old randomtable.Tree, files []string) (tree randomtable.Tree, err error) {
+	// A file that can't be rendered mustn't stop the server
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("loading tables panicked: %v", p)
+		}
+	}()
+	// Which of two tables with the same name is kept depends on the order that
+	// every file is loaded in
+	if old.HasDuplicates() {
+		return getTree()
+	}
+	tree = old.WithoutFiles(files...)
+	for _, file := range files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if err := parseMarkdown(file, tree); err != nil {
+			return tree, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	if tree.HasDuplicates() {
+		return getTree()
+	}
+	return tree, nil
+}
+
+// errorIssues returns the issues that are errors
+func errorIssues(issues []randomtable.Issue) []randomtable.Issue {
+	errs := []randomtable.Issue{}
+	for _, issue := range issues {
+		if issue.Severity == randomtable.SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// markdownFiles returns the markdown files affected by changes to the paths. A path that
+// isn't a markdown file is a directory, which covers the files that were loaded from it
+// and the files in it now.
+func markdownFiles(loaded []string, paths []string) []string {
+	files := map[string]bool{}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if filepath.Ext(path) == ".md" {
+			files[path] = true
+			continue
+		}
+		for _, file := range loaded {
+			if path == "." || strings.HasPrefix(file, path+string(filepath.Separator)) {
+				files[file] = true
+			}
+		}
+		filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err == nil && filepath.Ext(file) == ".md" {
+				files[file] = true
+			}
+			return nil
+		})
+	}
+	result := []string{}
+	for file := range files {
+		result = append(result, file)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func logTables(change string, tables []string) {
+	if len(tables) > 0 {
+		log.Printf("%s %d tables: %s", change, len(tables), strings.Join(tables, ", "))
+	}
+}
@@ -4,16 +4,16 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/justinian/dice"
+	"github.com/awwithro/makemea/randomtable"
 	"github.com/spf13/cobra"
 )
 
 var rollCmd = &cobra.Command{
-	Use:   "roll [dice string xdy[+z]]",
+	Use:   "roll [dice string xdy[+z], 4d6kh3, d%]",
 	Short: "Roll some dice ",
 	Run: func(cmd *cobra.Command, args []string) {
 		for _, arg := range args{
-			result, _, err := dice.Roll(arg)
+			result, err := randomtable.RollDice(arg)
 			if err != nil{
 				fmt.Printf("%v\n", err)
 			}else{
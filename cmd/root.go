@@ -2,15 +2,44 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/awwithro/makemea/randomtable"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 var Debug bool
+
+// Row is used to select a whole row from a multi-column table
+var Row bool
+
+// Duplicates is the policy for tables with the same name
+var Duplicates string
+
+// Funcs are the functions that templates are allowed to use
+var Funcs string
+
+// Explain is used to print every lookup and roll made to generate an item
+var Explain bool
+
+// Seed is used to get the same results every time
+var Seed int64
+
+// Bonus, Advantage and Disadvantage change the roll on the table
+var Bonus int
+var Advantage bool
+var Disadvantage bool
+
+// Set holds values such as region=north that every template can use
+var Set []string
+
+// Timeout stops generating an item when it takes too long
+var Timeout time.Duration
 var rootCmd = &cobra.Command{
 	Use:   "makemea <table_name>",
 	Short: "MakeMeA is a tool to let GMs roll on lookup tables composed in markdown",
@@ -21,11 +50,33 @@ and attempt to turn any tables in those files into tables that can be rolled on.
 		tableName := args[0]
 		tree := MustGetTree()
 		tree.ValidateTables()
-		item, err := tree.GetItem(tableName)
+		if cmd.Flags().Changed("seed") {
+			tree = tree.WithSeed(Seed)
+		}
+		values, err := parseValues(Set)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tree = tree.WithValues(values)
+		ctx := randomtable.WithLimits(context.Background(), randomtable.DefaultLimits)
+		if Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, Timeout)
+			defer cancel()
+		}
+		if Row {
+			printRow(ctx, tree, tableName)
+			return
+		}
+		mod := randomtable.RollModifier{Bonus: Bonus, Advantage: Advantage, Disadvantage: Disadvantage}
+		item, trace, err := tree.GetItemWithModifier(ctx, tableName, mod)
 		if err != nil {
 			log.Fatal(err)
 		}
 		fmt.Println(item)
+		if Explain {
+			fmt.Print(trace)
+		}
 
 	},
 	Args: cobra.MinimumNArgs(1),
@@ -37,6 +88,29 @@ and attempt to turn any tables in those files into tables that can be rolled on.
 	},
 }
 
+// parseValues reads values given as name=value
+func parseValues(settings []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, setting := range settings {
+		name, value, found := strings.Cut(setting, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("%q must be given as name=value", setting)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func printRow(ctx context.Context, tree randomtable.Tree, tableName string) {
+	row, columns, err := tree.GetRow(ctx, tableName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, column := range columns {
+		fmt.Printf("%s: %s\n", column, row[column])
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -44,16 +118,14 @@ func Execute() {
 	}
 }
 
-func parseMarkdown(path string, tree randomtable.Tree) {
+func parseMarkdown(path string, tree randomtable.Tree) error {
 	source, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	var buf bytes.Buffer
-	md := randomtable.NewMarkdownParser(tree)
-	if err := md.Convert(source, &buf); err != nil {
-		panic(err)
-	}
+	md := randomtable.NewMarkdownParser(tree.WithFile(path))
+	return md.Convert(source, &buf)
 }
 
 func loadTablesIntoTree(tree randomtable.Tree) error {
@@ -63,19 +135,41 @@ func loadTablesIntoTree(tree randomtable.Tree) error {
 				return err
 			}
 			if filepath.Ext(path) == ".md" {
-				parseMarkdown(path, tree)
+				return parseMarkdown(path, tree)
 			}
 			return nil
 		})
+	return err
+}
+
+// newTree returns an empty tree that uses the duplicate policy and functions from the flags
+func newTree() (randomtable.Tree, error) {
+	policy, err := randomtable.ParseDuplicatePolicy(Duplicates)
 	if err != nil {
-		log.Fatal(err)
+		return randomtable.Tree{}, err
+	}
+	tree := randomtable.NewTree().WithDuplicatePolicy(policy)
+	if Funcs == "" {
+		return tree, nil
+	}
+	funcs, err := randomtable.ParseFuncSet(Funcs)
+	if err != nil {
+		return tree, err
+	}
+	return tree.WithFuncs(funcs), nil
+}
+
+// getTree loads every table under the current directory
+func getTree() (randomtable.Tree, error) {
+	tree, err := newTree()
+	if err != nil {
+		return tree, err
 	}
-	return nil
+	return tree, loadTablesIntoTree(tree)
 }
 
 func MustGetTree() randomtable.Tree {
-	tree := randomtable.NewTree()
-	err := loadTablesIntoTree(tree)
+	tree, err := getTree()
 	if err != nil {
 		log.Fatalf("Unable to load tables: %v", err)
 	}
@@ -84,9 +178,22 @@ func MustGetTree() randomtable.Tree {
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&Debug,"debug", "d",false, "set debug logging")
+	rootCmd.PersistentFlags().StringVar(&Funcs, "funcs", "", "functions templates can use, such as all, safe, or safe,-date,env. safe has every function apart from env, expandenv, getHostByName and the functions that make keys and certificates. serve defaults to safe and everything else to all")
+	rootCmd.PersistentFlags().StringVar(&Duplicates, "duplicates", "last-wins", "what to do with tables that have the same name: last-wins, first-wins, merge-rows or error")
+	rootCmd.Flags().Int64VarP(&Seed, "seed", "s", 0, "seed for the random results. The same seed and tables give the same result")
+	rootCmd.Flags().BoolVarP(&Row, "row", "r", false, "select a whole row from a table with more than one column")
+	rootCmd.Flags().BoolVarP(&Explain, "explain", "e", false, "print every lookup and roll that was made to generate the item")
+	rootCmd.Flags().IntVarP(&Bonus, "bonus", "b", 0, "added to the roll on the table. Results past the first or last row get that row")
+	rootCmd.Flags().BoolVar(&Advantage, "advantage", false, "roll twice on the table and keep the higher roll")
+	rootCmd.Flags().BoolVar(&Disadvantage, "disadvantage", false, "roll twice on the table and keep the lower roll")
+	rootCmd.Flags().DurationVar(&Timeout, "timeout", 0, "stop generating the item after this long, such as 5s. 0 has no timeout")
+	rootCmd.Flags().StringArrayVar(&Set, "set", nil, "set a value that every template can use, such as --set region=north. Can be given more than once")
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(rollCmd)
+	rootCmd.AddCommand(oddsCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(lintCmd)
 }
@@ -2,16 +2,16 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"time"
 
+	"github.com/awwithro/makemea/randomtable"
 	"github.com/awwithro/makemea/server"
 	"github.com/spf13/cobra"
-	"golang.org/x/mod/sumdb/dirhash"
 )
 
 var port string
 var addr string
+var serveTimeout time.Duration
 
 var serveCmd = &cobra.Command{
 	Use:   "serve [prefix]",
@@ -21,30 +21,25 @@ var serveCmd = &cobra.Command{
 
 func init() {
 	serveCmd.PersistentFlags().StringVarP(&port, "port", "p", ":8080", "Port for the server to listen on (:8181)")
+	serveCmd.Flags().DurationVar(&serveTimeout, "timeout", 10*time.Second, "stop generating an item after this long. 0 has no timeout")
 	serveCmd.PersistentFlags().StringVarP(&addr, "addr", "a", "127.0.0.1", "Address for the server to listen on (127.0.0.1)")
 }
 
 func serveCommand(cmd *cobra.Command, args []string) {
+	// Tables may be written by anyone so they can't read the server's environment
+	if Funcs == "" {
+		Funcs = "safe"
+	}
 	tree := MustGetTree().WithHtmlFormatter()
 	tree.ValidateTables()
-	srv := server.NewServer(&tree)
-	ticker := time.NewTicker(5 * time.Second)
-	dir, _ := os.Getwd()
-	hash, _ := dirhash.HashDir(dir, "", dirhash.DefaultHash)
-	go func() {
-		for {
-			select {
-			// Check for updated files every 5 seconds and reload the tree if things have changed
-			case <-ticker.C:
-				newHash, _ := dirhash.HashDir(dir, "", dirhash.DefaultHash)
-				if hash != newHash {
-					log.Print("Files have changed, reloading tables")
-					newTree := MustGetTree().WithHtmlFormatter()
-					*&tree = newTree
-					hash = newHash
-				}
-			}
-		}
-	}()
+	shared := randomtable.NewSharedTree(tree)
+	srv := server.NewServer(shared, serveTimeout)
+	// Reload the tables from any markdown files that change
+	changes, err := watchFiles(".")
+	if err != nil {
+		log.Fatalf("Unable to watch files: %v", err)
+	}
+	r := &reloader{shared: shared, prepare: randomtable.Tree.WithHtmlFormatter}
+	go r.watch(changes)
 	srv.Run(addr + port)
 }
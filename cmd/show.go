@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/awwithro/makemea/randomtable"
@@ -11,12 +14,31 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// ShowFormat is the format tables are printed in
+var ShowFormat string
+
 func show(tree randomtable.Tree, tableName string) {
-	t, name, err := tree.GetTable(tableName)
+	desc, err := tree.Describe(tableName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	s := strings.Split(name, "/")
+	switch ShowFormat {
+	case "markdown":
+		showMarkdown(desc)
+	case "json":
+		out, err := json.MarshalIndent(desc, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+	default:
+		log.Fatalf("unknown format %q, use markdown or json", ShowFormat)
+	}
+}
+
+// showMarkdown prints the rows of the table with the rolls, weight or copies of each row
+func showMarkdown(desc randomtable.Description) {
+	s := strings.Split(desc.Name, "/")
 	shortName := s[len(s)-1]
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
@@ -27,13 +49,37 @@ func show(tree randomtable.Tree, tableName string) {
 	table.SetColWidth(1000)
 	table.SetReflowDuringAutoWrap(false)
 	table.SetRowLine(false)
-	table = t.GetTable(table, strings.Title(shortName))
+	header := []string{strings.Title(shortName)}
+	switch desc.Kind {
+	case randomtable.KindDice:
+		header = append(header, desc.Dice)
+	case randomtable.KindWeighted:
+		header = append(header, randomtable.WEIGHT_COLUMN_NAME)
+	case randomtable.KindDeck:
+		header = append(header, randomtable.DECK_COLUMN_NAME)
+	}
+	table.SetHeader(header)
+	for _, row := range desc.Rows {
+		cells := []string{row.Item}
+		switch {
+		case row.Range != nil:
+			cells = append(cells, row.Range.String())
+		case row.InvalidWeight != "":
+			cells = append(cells, row.InvalidWeight)
+		case row.Weight != nil:
+			cells = append(cells, strconv.Itoa(*row.Weight))
+		}
+		table.Append(cells)
+	}
 	table.Render()
 }
 
 var showCmd = &cobra.Command{
 	Use:   "show [table]",
 	Short: "Prints the contents of a table in markdown format",
+	Long: `Prints the contents of a table in markdown format with the rolls, weight or
+copies of each row. The JSON format also gives the kind of the table and where
+it and each of its rows were found.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var tableName string
 		tableName = args[0]
@@ -50,4 +96,5 @@ var showCmd = &cobra.Command{
 }
 
 func init() {
+	showCmd.PersistentFlags().StringVarP(&ShowFormat, "format", "f", "markdown", "format of the table, markdown or json")
 }
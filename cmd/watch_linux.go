@@ -0,0 +1,108 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotifyWatcher reports the files and directories under a directory that change
+type inotifyWatcher struct {
+	fd   int
+	root string
+	// dirs maps each watch to the directory it watches
+	dirs    map[int]string
+	changes chan string
+}
+
+// watchFiles reports the path of every file or directory under root that changes.
+// Hidden directories such as .git aren't watched.
+func watchFiles(root string) (<-chan string, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{fd: fd, root: root, dirs: map[int]string{}, changes: make(chan string)}
+	if err := w.addDir(root); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	go w.run()
+	return w.changes, nil
+}
+
+// addDir watches the directory and every directory below it
+func (w *inotifyWatcher) addDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != w.root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return err
+		}
+		w.dirs[wd] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			log.Printf("Unable to watch files: %v", err)
+			close(w.changes)
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[start:start+int(event.Len)], "\x00"))
+			offset = start + int(event.Len)
+			w.handle(event, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(event *unix.InotifyEvent, name string) {
+	switch {
+	// Events were lost so everything may have changed
+	case event.Mask&unix.IN_Q_OVERFLOW != 0:
+		w.changes <- w.root
+		return
+	case event.Mask&unix.IN_IGNORED != 0:
+		delete(w.dirs, int(event.Wd))
+		return
+	}
+	dir, found := w.dirs[int(event.Wd)]
+	if !found || name == "" {
+		return
+	}
+	path := filepath.Join(dir, name)
+	// New directories need watching too
+	if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		if err := w.addDir(path); err != nil {
+			log.Printf("Unable to watch %s: %v", path, err)
+		}
+	}
+	w.changes <- path
+}
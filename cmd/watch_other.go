@@ -0,0 +1,67 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often markdown files are checked for changes
+const pollInterval = 2 * time.Second
+
+// watchFiles reports the path of every markdown file under root that changes.
+// Platforms without inotify check the size and modification time of each file.
+func watchFiles(root string) (<-chan string, error) {
+	changes := make(chan string)
+	last, err := statMarkdown(root)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for range time.Tick(pollInterval) {
+			current, err := statMarkdown(root)
+			if err != nil {
+				continue
+			}
+			for path, info := range current {
+				if prev, found := last[path]; !found || prev != info {
+					changes <- path
+				}
+			}
+			for path := range last {
+				if _, found := current[path]; !found {
+					changes <- path
+				}
+			}
+			last = current
+		}
+	}()
+	return changes, nil
+}
+
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+func statMarkdown(root string) (map[string]fileStat, error) {
+	stats := map[string]fileStat{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		stats[path] = fileStat{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return stats, err
+}
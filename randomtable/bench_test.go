@@ -0,0 +1,86 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// nestedGenerator builds an npc from lookups that are themselves made of lookups
+const nestedGenerator = `
+# NPC
+
+| Npc                                                                          |
+| ---------------------------------------------------------------------------- |
+| {{lookup "./name"}} the {{lookup "./job"}} is {{lookup "./mood"}} and {{lookup "./look"}} |
+
+| 1d4 | Name                                  |
+| --- | ------------------------------------- |
+| 1-2 | {{lookup "./first"}} {{lookup "./last"}} |
+| 3-4 | {{lookup "./first"}} of {{lookup "./place"}} |
+
+| First |
+| ----- |
+| Ann   |
+| Bob   |
+| Cid   |
+
+| Last              |
+| ----------------- |
+| Smith             |
+| {{lookup "./job"}}son |
+
+| Place                                 |
+| ------------------------------------- |
+| {{lookup "./mood" }} {{ pick "Hill" "Dale" "Ford" }} |
+
+| Job                   | weight |
+| --------------------- | ------ |
+| Baker                 | 2      |
+| {{ lookup "./mood" }} Smith | 1      |
+
+| Mood                                     |
+| ---------------------------------------- |
+| {{ chance 50 "happy" "sad" }}              |
+| {{ if eq (roll "1d6") "6" }}angry{{ else }}calm{{ end }} |
+
+| Look                                            |
+| ----------------------------------------------- |
+| {{ roll "1d20" }} years older than they look      |
+| scarred by {{ lower (lookup "./job") }} tools     |
+`
+
+func BenchmarkGetItemNested(b *testing.B) {
+	tree := NewTree().WithSeed(1)
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(nestedGenerator), &buf); err != nil {
+		b.Fatal(err)
+	}
+	if issues := tree.Validate(); MaxSeverity(issues) >= SeverityWarning {
+		b.Fatal(issues)
+	}
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		if _, err := tree.GetItem(context.Background(), "npc/npc"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetItemNestedParallel(b *testing.B) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(nestedGenerator), &buf); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := tree.GetItem(context.Background(), "npc/npc"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package randomtable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LookupDepthError is returned when lookups are nested deeper than the tree allows. This
+// is almost always caused by a table that looks itself up, directly or through other tables.
+type LookupDepthError struct {
+	Depth int
+	// Chain holds every table that was looked up, starting with the first
+	Chain []string
+}
+
+func (e *LookupDepthError) Error() string {
+	return fmt.Sprintf("lookups nested more than %d deep: %s", e.Depth, formatChain(e.Chain))
+}
+
+// formatChain joins the tables of a chain, stopping at the first table that is repeated
+// so that a cycle is only shown once
+func formatChain(chain []string) string {
+	seen := map[string]bool{}
+	for i, table := range chain {
+		if seen[table] {
+			suffix := ""
+			if i < len(chain)-1 {
+				suffix = " -> ..."
+			}
+			return strings.Join(chain[:i+1], " -> ") + suffix
+		}
+		seen[table] = true
+	}
+	return strings.Join(chain, " -> ")
+}
+
+// lookupCycles returns the chains of tables whose templates lead back to a table already in
+// the chain. Each chain starts and ends with the same table.
+func (t *Tree) lookupCycles() [][]string {
+	graph := t.lookupGraph()
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+	cycles := [][]string{}
+	var visit func(table string)
+	visit = func(table string) {
+		state[table] = visiting
+		stack = append(stack, table)
+		for _, next := range graph[table] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == next {
+						cycle := append([]string{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, next))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[table] = visited
+	}
+	tables := make([]string, 0, len(graph))
+	for table := range graph {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if state[table] == unvisited {
+			visit(table)
+		}
+	}
+	return cycles
+}
+
+// lookupGraph maps each table to the tables its items render items from. Links are followed
+// so that every name is the name of a table.
+func (t *Tree) lookupGraph() map[string][]string {
+	targets := map[string]map[string]bool{}
+	for _, ref := range t.References() {
+		// Shuffling and counting cards don't render anything
+		if !tableFuncs[ref.Function] || ref.Error != "" {
+			continue
+		}
+		target, name, err := t.GetTable(ref.Target)
+		if err != nil {
+			continue
+		}
+		if targets[ref.Table] == nil {
+			targets[ref.Table] = map[string]bool{}
+		}
+		targets[ref.Table][name] = true
+		// A row renders the cells of every column
+		if ref.Function == "lookupRow" && target.Row != nil {
+			for _, column := range target.Row.tables {
+				targets[ref.Table][column] = true
+			}
+		}
+	}
+	graph := map[string][]string{}
+	for table, names := range targets {
+		for name := range names {
+			graph[table] = append(graph[table], name)
+		}
+		sort.Strings(graph[table])
+	}
+	return graph
+}
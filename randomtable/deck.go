@@ -0,0 +1,114 @@
+package randomtable
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// deckStore holds the state of every deck that has been drawn from, as well as the
+// recent results of tables that shouldn't repeat. It is shared by copies of a Tree.
+type deckStore struct {
+	mu      sync.Mutex
+	decks   map[string][]string
+	history map[string][]string
+}
+
+func newDeckStore() *deckStore {
+	return &deckStore{
+		decks:   map[string][]string{},
+		history: map[string][]string{},
+	}
+}
+
+// deckCards returns the cards that make up a full deck for the given table.
+// Tables that aren't decks use each of their items as a single card.
+func deckCards(table Table) []string {
+	switch tb := table.(type) {
+	case *DeckTable:
+		return tb.Cards()
+	case *WeightedTable:
+		return DeckTable{WeightedTable: *tb}.Cards()
+	default:
+		cards := []string{}
+		return append(cards, table.AllItems()...)
+	}
+}
+
+// shuffle puts every card back into the named deck
+func (d *deckStore) shuffle(name string, table Table, r *rand.Rand) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shuffleLocked(name, table, r)
+}
+
+func (d *deckStore) shuffleLocked(name string, table Table, r *rand.Rand) {
+	cards := deckCards(table)
+	r.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+	d.decks[name] = cards
+}
+
+// draw deals the next card from the named deck. The deck is shuffled when it is
+// first used and again once every card has been dealt. A deck without any cards
+// returns ErrEmptyTable.
+func (d *deckStore) draw(name string, table Table, r *rand.Rand) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cards, found := d.decks[name]
+	if !found || len(cards) == 0 {
+		d.shuffleLocked(name, table, r)
+		cards = d.decks[name]
+	}
+	if len(cards) == 0 {
+		return "", ErrEmptyTable
+	}
+	card := cards[len(cards)-1]
+	d.decks[name] = cards[:len(cards)-1]
+	return card, nil
+}
+
+// remaining is the number of cards left in the named deck
+func (d *deckStore) remaining(name string, table Table) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cards, found := d.decks[name]
+	if !found {
+		return len(deckCards(table))
+	}
+	return len(cards)
+}
+
+// recent returns true if the item is one of the last n results for the named table.
+// Nothing is recent when n isn't positive.
+func (d *deckStore) recent(name, item string, n int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	history := d.history[name]
+	if n > len(history) {
+		n = len(history)
+	}
+	if n < 0 {
+		n = 0
+	}
+	for _, h := range history[len(history)-n:] {
+		if h == item {
+			return true
+		}
+	}
+	return false
+}
+
+// remember records a result for the named table, keeping at most n results
+func (d *deckStore) remember(name, item string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	history := append(d.history[name], item)
+	if len(history) > n {
+		history = history[len(history)-n:]
+	}
+	d.history[name] = history
+}
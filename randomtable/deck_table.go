@@ -0,0 +1,51 @@
+package randomtable
+
+import "strings"
+
+// DECK_COLUMN_NAME is the header text that marks a column as holding the number of copies of each card
+const DECK_COLUMN_NAME = "deck"
+
+// maxCopies is the most copies of a card a deck can have. Validate reports more copies, and
+// only maxCopies of them are dealt so a deck can't take up more memory than that.
+const maxCopies = 10000
+
+// DeckTable is a table whose items are dealt out like cards. Each item is returned once
+// until the deck runs out. The state of the deck is held by the Tree, so GetItem on the
+// table itself behaves like a freshly shuffled deck every time.
+type DeckTable struct {
+	WeightedTable
+}
+
+// AddCard adds an item with the given number of copies. An empty string is a single copy
+func (d *DeckTable) AddCard(item string, copies string) {
+	if strings.TrimSpace(copies) == "" {
+		copies = "1"
+	}
+	d.AddWeightedItem(item, copies)
+}
+
+// Cards returns every card in the deck with copies repeated, up to maxCopies of each
+func (d DeckTable) Cards() []string {
+	cards := []string{}
+	for i, item := range d.items {
+		for c := 0; c < min(d.weights[i], maxCopies); c++ {
+			cards = append(cards, item)
+		}
+	}
+	return cards
+}
+
+// Validate checks the copies of each card like the weights of a weighted table, with no
+// more than maxCopies of any card
+func (d *DeckTable) Validate() []Issue {
+	return d.validateWeights(maxCopies, "%s has %v copies, which is over the limit of %d")
+}
+
+// Describe lists each card with its number of copies as the weight
+func (d DeckTable) Describe() Description {
+	return Description{Kind: KindDeck, Rows: d.describeRows()}
+}
+
+func NewDeckTable() DeckTable {
+	return DeckTable{WeightedTable: NewWeightedTable()}
+}
@@ -0,0 +1,107 @@
+package randomtable
+
+// TableKind is the kind of a table, which decides how an item is picked from its rows
+type TableKind string
+
+const (
+	// KindRandom picks any row with the same chance
+	KindRandom TableKind = "random"
+	// KindDice rolls dice and picks the row with the roll
+	KindDice TableKind = "dice"
+	// KindWeighted picks rows in proportion to their weight
+	KindWeighted TableKind = "weighted"
+	// KindDeck deals rows like cards, with as many cards as the copies of each row
+	KindDeck TableKind = "deck"
+	// KindText always gives its text
+	KindText TableKind = "text"
+)
+
+// Description is everything that's known about a table, in a form that can be shown or
+// exported in any way without reaching into the table itself
+type Description struct {
+	// Name is the full name of the table. Tables that are described by themselves don't
+	// know their name.
+	Name string    `json:"name,omitempty"`
+	Kind TableKind `json:"kind"`
+	// Dice are rolled to pick a row of a dice table
+	Dice   string `json:"dice,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+	// Columns are the headers of the markdown table the table is a column of
+	Columns []string         `json:"columns,omitempty"`
+	Source  Source           `json:"source"`
+	Rows    []RowDescription `json:"rows"`
+}
+
+// RowDescription is a single row of a table, in the order the rows were added
+type RowDescription struct {
+	Item string `json:"item"`
+	// Range is the rolls given for the row of a dice table. A later row takes any rolls
+	// it shares with an earlier one.
+	Range *Range `json:"range,omitempty"`
+	// Weight is the weight of a row on a weighted table or the copies of a card in a deck
+	Weight *int `json:"weight,omitempty"`
+	// InvalidWeight is the weight as it was written when it isn't a number. The row has
+	// a weight of 0.
+	InvalidWeight string `json:"invalidWeight,omitempty"`
+	// Source is where the row was found, and has no lines when that isn't known
+	Source Source `json:"source"`
+}
+
+// rowSources records where each row of a table was found
+type rowSources []Source
+
+// sourcedTable is a table that can record where each of its rows was found
+type sourcedTable interface {
+	rowCount() int
+	setRowSource(row int, src Source)
+}
+
+func (s *rowSources) setRowSource(row int, src Source) {
+	for len(*s) <= row {
+		*s = append(*s, Source{})
+	}
+	(*s)[row] = src
+}
+
+// addSourcedItem adds an item to the table with add and records that the row it added was
+// found at src. Nothing is recorded when add doesn't add a row, such as a roll that isn't a
+// number.
+func addSourcedItem(table Table, src Source, add func() error) error {
+	sourced, ok := table.(sourcedTable)
+	if !ok {
+		return add()
+	}
+	rows := sourced.rowCount()
+	if err := add(); err != nil {
+		return err
+	}
+	if sourced.rowCount() > rows {
+		sourced.setRowSource(rows, src)
+	}
+	return nil
+}
+
+// rowSource returns where the row was found, or an empty source when it isn't known
+func (s rowSources) rowSource(row int) Source {
+	if row < len(s) {
+		return s[row]
+	}
+	return Source{}
+}
+
+// Describe returns a description of the named table. Links are followed to the table
+// they point to.
+func (t *Tree) Describe(name string) (Description, error) {
+	tb, name, err := t.GetTable(name)
+	if err != nil {
+		return Description{}, err
+	}
+	desc := tb.Describe()
+	desc.Name = name
+	desc.Hidden = tb.Hidden
+	desc.Source = tb.Source
+	if tb.Row != nil {
+		desc.Columns = tb.Row.Headers()
+	}
+	return desc, nil
+}
@@ -0,0 +1,95 @@
+package randomtable
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const describeTest = `# Describe
+
+| 1d6 | Weather | Wind  |
+| --- | ------- | ----- |
+| 1-3 | Sun     | Calm  |
+| bad | Never   | Never |
+| 4-6 | Rain    | Gusty |
+
+| Loot  | weight |
+| ----- | ------ |
+| Coin  | 3      |
+| Gem   | lots   |
+
+| Card | deck |
+| ---- | ---- |
+| Ace  | 2    |
+
+| Name |
+| ---- |
+| Ann  |
+
+[Sky](describe/weather)
+
+` + "```" + ` note
+Just text
+` + "```" + `
+`
+
+func TestDescribe(t *testing.T) {
+	tree := NewTree().WithFile("describe.md")
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(describeTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	two, three := 2, 3
+	tests := []struct {
+		table    string
+		expected Description
+	}{
+		{table: "describe/sky", expected: Description{
+			Name: "describe/weather", Kind: KindDice, Dice: "1d6", Columns: []string{"Weather", "Wind"},
+			Source: Source{File: "describe.md", Line: 3, EndLine: 7},
+			Rows: []RowDescription{
+				{Item: "Sun", Range: &Range{1, 3}, Source: Source{File: "describe.md", Line: 5, EndLine: 5}},
+				{Item: "Rain", Range: &Range{4, 6}, Source: Source{File: "describe.md", Line: 7, EndLine: 7}},
+			},
+		}},
+		{table: "describe/loot", expected: Description{
+			Name: "describe/loot", Kind: KindWeighted, Columns: []string{"Loot"},
+			Source: Source{File: "describe.md", Line: 9, EndLine: 12},
+			Rows: []RowDescription{
+				{Item: "Coin", Weight: &three, Source: Source{File: "describe.md", Line: 11, EndLine: 11}},
+				{Item: "Gem", Weight: new(int), InvalidWeight: "lots", Source: Source{File: "describe.md", Line: 12, EndLine: 12}},
+			},
+		}},
+		{table: "describe/card", expected: Description{
+			Name: "describe/card", Kind: KindDeck, Columns: []string{"Card"},
+			Source: Source{File: "describe.md", Line: 14, EndLine: 16},
+			Rows: []RowDescription{
+				{Item: "Ace", Weight: &two, Source: Source{File: "describe.md", Line: 16, EndLine: 16}},
+			},
+		}},
+		{table: "describe/name", expected: Description{
+			Name: "describe/name", Kind: KindRandom, Columns: []string{"Name"},
+			Source: Source{File: "describe.md", Line: 18, EndLine: 20},
+			Rows:   []RowDescription{{Item: "Ann", Source: Source{File: "describe.md", Line: 20, EndLine: 20}}},
+		}},
+		{table: "describe/note", expected: Description{
+			Name: "describe/note", Kind: KindText,
+			Source: Source{File: "describe.md", Line: 24, EndLine: 26},
+			Rows:   []RowDescription{{Item: "Just text\n", Source: Source{File: "describe.md", Line: 24, EndLine: 26}}},
+		}},
+	}
+	for _, tc := range tests {
+		desc, err := tree.Describe(tc.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(desc, tc.expected) {
+			t.Errorf("%s: expected\n%+v\nbut got\n%+v", tc.table, tc.expected, desc)
+		}
+	}
+	if _, err := tree.Describe("describe/missing"); err == nil {
+		t.Error("Expected an error for a table that doesn't exist")
+	}
+}
@@ -0,0 +1,953 @@
+package randomtable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxExplosions limits how many times a single exploding die can be rolled again
+const maxExplosions = 100
+
+// maxDiceCount and maxDiceSides bound a single group of dice, so that an expression can't
+// ask for more memory than a roll could ever sensibly need
+const (
+	maxDiceCount = 100000
+	maxDiceSides = 1000000
+)
+
+// maxOutcomePairs limits the work done combining outcomes when multiplying or dividing dice
+const maxOutcomePairs = 1000000
+
+// maxCombinations limits how many rolls of a group are looked at to find the odds of
+// keeping or dropping dice
+const maxCombinations = 1000000
+
+// minChance is the smallest chance that is kept when working out the odds of exploding dice
+const minChance = 1e-12
+
+// Dice is a parsed dice expression. It supports
+//
+//	NdM       N dice with M sides. N defaults to 1 (d20)
+//	d%        percentile dice, the same as d100
+//	NdF       fate dice that roll -1, 0 or 1
+//	khN klN   keep the highest or lowest N dice. kN is the same as khN
+//	dhN dlN   drop the highest or lowest N dice. dN is the same as dlN
+//	!         explode, rolling again and adding when a die rolls its highest number
+//	rN roN    reroll dice that roll N, forever or only once
+//	+ - * /   arithmetic between dice and numbers, with division rounded down
+//
+// Explode and reroll take an optional compare point such as !>5 or r<2. As with most
+// online rollers, > and < include the number given.
+type Dice struct {
+	expr string
+	root diceNode
+}
+
+// DiceResult is the outcome of rolling a Dice expression
+type DiceResult struct {
+	Total   int
+	Rolls   []int
+	Dropped []int
+}
+
+// Int returns the total of the roll
+func (r DiceResult) Int() int {
+	return r.Total
+}
+
+// String describes the roll with each die that was kept and dropped
+func (r DiceResult) String() string {
+	if len(r.Dropped) == 0 {
+		return fmt.Sprintf("%d %v", r.Total, r.Rolls)
+	}
+	return fmt.Sprintf("%d %v (%v)", r.Total, r.Rolls, r.Dropped)
+}
+
+// Range is an inclusive range of numbers
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// String formats the range as a single number when min and max are the same, or min-max
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprint(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// ParseDice parses a dice expression such as 2d6+1, 4d6kh3 or d%
+func ParseDice(expr string) (Dice, error) {
+	p := &diceParser{input: strings.ToLower(strings.Join(strings.Fields(expr), ""))}
+	if p.input == "" {
+		return Dice{}, errors.New("empty dice string")
+	}
+	root, err := p.expr()
+	if err != nil {
+		return Dice{}, fmt.Errorf("bad dice string %q: %w", expr, err)
+	}
+	if p.pos < len(p.input) {
+		return Dice{}, fmt.Errorf("bad dice string %q: unexpected %q", expr, p.input[p.pos:])
+	}
+	if !p.sawDice {
+		return Dice{}, fmt.Errorf("bad dice string %q: no dice to roll", expr)
+	}
+	return Dice{expr: expr, root: root}, nil
+}
+
+// IsDice returns true if the string is a valid dice expression
+func IsDice(expr string) bool {
+	_, err := ParseDice(expr)
+	return err == nil
+}
+
+// RollDice parses and rolls a dice expression
+func RollDice(expr string) (DiceResult, error) {
+	d, err := ParseDice(expr)
+	if err != nil {
+		return DiceResult{}, err
+	}
+	return d.Roll(nil)
+}
+
+// RollDiceContext parses and rolls a dice expression like RollDice. The dice count towards
+// the limit on dice from the context.
+func RollDiceContext(ctx context.Context, expr string) (DiceResult, error) {
+	d, err := ParseDice(expr)
+	if err != nil {
+		return DiceResult{}, err
+	}
+	if err := newGeneration(ctx).rollDice(d.Count()); err != nil {
+		return DiceResult{}, err
+	}
+	return d.Roll(nil)
+}
+
+// Roll rolls the dice using the given source of randomness. A nil source
+// will use the default source from math/rand.
+func (d Dice) Roll(r *rand.Rand) (DiceResult, error) {
+	result := DiceResult{}
+	if d.root == nil {
+		return result, errors.New("no dice to roll")
+	}
+	total, err := d.root.roll(diceRand{r}, &result)
+	result.Total = total
+	return result, err
+}
+
+// Count returns how many dice the expression rolls, not counting rerolls and explosions
+func (d Dice) Count() int {
+	return countDice(d.root)
+}
+
+func countDice(node diceNode) int {
+	switch n := node.(type) {
+	case negNode:
+		return countDice(n.node)
+	case binaryNode:
+		return countDice(n.left) + countDice(n.right)
+	case groupNode:
+		return n.count
+	}
+	return 0
+}
+
+// Outcomes returns every total that the dice can roll as sorted, non-overlapping ranges
+func (d Dice) Outcomes() ([]Range, error) {
+	if d.root == nil {
+		return nil, errors.New("no dice to roll")
+	}
+	set, err := d.root.outcomes()
+	return []Range(set), err
+}
+
+// Distribution returns the chance of rolling each total. An error is returned when
+// the dice are too complex to work out exactly.
+func (d Dice) Distribution() (map[int]float64, error) {
+	if d.root == nil {
+		return nil, errors.New("no dice to roll")
+	}
+	dist, err := d.root.distribution()
+	return map[int]float64(dist), err
+}
+
+// String returns the expression the dice were parsed from
+func (d Dice) String() string {
+	return d.expr
+}
+
+// diceRand wraps an optional source of randomness
+type diceRand struct {
+	r *rand.Rand
+}
+
+func (d diceRand) Intn(n int) int {
+	if d.r == nil {
+		return rand.Intn(n)
+	}
+	return d.r.Intn(n)
+}
+
+type diceNode interface {
+	roll(r diceRand, result *DiceResult) (int, error)
+	outcomes() (rangeSet, error)
+	distribution() (diceDist, error)
+}
+
+type constNode struct {
+	value int
+}
+
+func (c constNode) roll(r diceRand, result *DiceResult) (int, error) {
+	return c.value, nil
+}
+
+func (c constNode) outcomes() (rangeSet, error) {
+	return rangeSet{{c.value, c.value}}, nil
+}
+
+func (c constNode) distribution() (diceDist, error) {
+	return diceDist{c.value: 1}, nil
+}
+
+type negNode struct {
+	node diceNode
+}
+
+func (n negNode) roll(r diceRand, result *DiceResult) (int, error) {
+	v, err := n.node.roll(r, result)
+	return -v, err
+}
+
+func (n negNode) outcomes() (rangeSet, error) {
+	set, err := n.node.outcomes()
+	if err != nil {
+		return nil, err
+	}
+	return set.negate(), nil
+}
+
+func (n negNode) distribution() (diceDist, error) {
+	dist, err := n.node.distribution()
+	if err != nil {
+		return nil, err
+	}
+	neg := diceDist{}
+	for v, p := range dist {
+		neg[-v] = p
+	}
+	return neg, nil
+}
+
+type binaryNode struct {
+	op          byte
+	left, right diceNode
+}
+
+func (b binaryNode) roll(r diceRand, result *DiceResult) (int, error) {
+	left, err := b.left.roll(r, result)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.roll(r, result)
+	if err != nil {
+		return 0, err
+	}
+	return applyOp(b.op, left, right)
+}
+
+func (b binaryNode) outcomes() (rangeSet, error) {
+	left, err := b.left.outcomes()
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.right.outcomes()
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case '+':
+		return left.add(right), nil
+	case '-':
+		return left.add(right.negate()), nil
+	}
+	// Multiplication and division don't keep ranges together so each pair is combined
+	if left.size()*right.size() > maxOutcomePairs {
+		return nil, errors.New("too many outcomes to compute")
+	}
+	values := []int{}
+	for _, l := range left.values() {
+		for _, r := range right.values() {
+			v, err := applyOp(b.op, l, r)
+			// Dividing by zero is only an error if it is rolled
+			if err != nil {
+				continue
+			}
+			values = append(values, v)
+		}
+	}
+	return newRangeSet(values), nil
+}
+
+func (b binaryNode) distribution() (diceDist, error) {
+	left, err := b.left.distribution()
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.right.distribution()
+	if err != nil {
+		return nil, err
+	}
+	if len(left)*len(right) > maxOutcomePairs {
+		return nil, errors.New("too many outcomes to compute")
+	}
+	dist := diceDist{}
+	// Chances of dividing by zero are left out
+	missing := 0.0
+	for l, lp := range left {
+		for r, rp := range right {
+			v, err := applyOp(b.op, l, r)
+			if err != nil {
+				missing += lp * rp
+				continue
+			}
+			dist[v] += lp * rp
+		}
+	}
+	if missing > 0 && missing < 1 {
+		for v := range dist {
+			dist[v] /= 1 - missing
+		}
+	}
+	return dist, nil
+}
+
+func applyOp(op byte, left, right int) (int, error) {
+	switch op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		// Round down rather than towards zero
+		q := left / right
+		if (left%right != 0) && ((left < 0) != (right < 0)) {
+			q--
+		}
+		return q, nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", op)
+}
+
+// comparePoint decides which rolls explode or are rerolled
+type comparePoint struct {
+	op    byte
+	value int
+}
+
+func (c comparePoint) matches(v int) bool {
+	switch c.op {
+	case '>':
+		return v >= c.value
+	case '<':
+		return v <= c.value
+	}
+	return v == c.value
+}
+
+// faces returns the faces of a die, lo to hi, that match the compare point
+func (c comparePoint) faces(lo, hi int) rangeSet {
+	switch c.op {
+	case '>':
+		return rangeSet{{c.value, hi}}.clamp(lo, hi)
+	case '<':
+		return rangeSet{{lo, c.value}}.clamp(lo, hi)
+	}
+	return rangeSet{{c.value, c.value}}.clamp(lo, hi)
+}
+
+type groupNode struct {
+	count      int
+	lo, hi     int
+	keep       string
+	keepCount  int
+	explode    *comparePoint
+	reroll     *comparePoint
+	rerollOnce bool
+}
+
+func (g groupNode) face(r diceRand) int {
+	return g.lo + r.Intn(g.hi-g.lo+1)
+}
+
+func (g groupNode) rollDie(r diceRand) int {
+	v := g.face(r)
+	if g.reroll != nil {
+		if g.rerollOnce {
+			if g.reroll.matches(v) {
+				v = g.face(r)
+			}
+		} else {
+			for g.reroll.matches(v) {
+				v = g.face(r)
+			}
+		}
+	}
+	if g.explode != nil {
+		total := v
+		for x := 0; x < maxExplosions && g.explode.matches(v); x++ {
+			v = g.face(r)
+			total += v
+		}
+		v = total
+	}
+	return v
+}
+
+// kept returns the number of dice that are added to the total
+func (g groupNode) kept() int {
+	switch g.keep {
+	case "kh", "kl":
+		if g.keepCount < g.count {
+			return g.keepCount
+		}
+		return g.count
+	case "dh", "dl":
+		if g.keepCount > g.count {
+			return 0
+		}
+		return g.count - g.keepCount
+	}
+	return g.count
+}
+
+func (g groupNode) roll(r diceRand, result *DiceResult) (int, error) {
+	rolls := make([]int, g.count)
+	for i := range rolls {
+		rolls[i] = g.rollDie(r)
+	}
+	sort.Ints(rolls)
+	kept := g.kept()
+	var keep, dropped []int
+	switch g.keep {
+	case "kh", "dl":
+		dropped, keep = rolls[:g.count-kept], rolls[g.count-kept:]
+	case "kl", "dh":
+		keep, dropped = rolls[:kept], rolls[kept:]
+	default:
+		keep = rolls
+	}
+	total := 0
+	for _, v := range keep {
+		total += v
+	}
+	result.Rolls = append(result.Rolls, keep...)
+	result.Dropped = append(result.Dropped, dropped...)
+	return total, nil
+}
+
+// dieOutcomes returns every value a single die can have after rerolls and explosions
+func (g groupNode) dieOutcomes() rangeSet {
+	faces := rangeSet{{g.lo, g.hi}}
+	if g.reroll != nil && !g.rerollOnce {
+		faces = faces.subtract(g.reroll.faces(g.lo, g.hi))
+	}
+	if g.explode == nil {
+		return faces
+	}
+	// Work back from the last explosion, which can't explode again. Only the
+	// first roll of the die is rerolled.
+	explodes := g.explode.faces(g.lo, g.hi)
+	all := rangeSet{{g.lo, g.hi}}
+	values := all
+	for x := 1; x <= maxExplosions; x++ {
+		first := all
+		if x == maxExplosions {
+			first = faces
+		}
+		values = first.subtract(explodes).union(first.intersect(explodes).add(values))
+	}
+	return values
+}
+
+func (g groupNode) outcomes() (rangeSet, error) {
+	return g.dieOutcomes().times(g.kept()), nil
+}
+
+// dieDistribution returns the chance of each value for a single die after rerolls and explosions
+func (g groupNode) dieDistribution() diceDist {
+	sides := g.hi - g.lo + 1
+	all := diceDist{}
+	for v := g.lo; v <= g.hi; v++ {
+		all[v] = 1 / float64(sides)
+	}
+	first := all
+	if g.reroll != nil {
+		rerolled := g.reroll.faces(g.lo, g.hi).size()
+		first = diceDist{}
+		for v := g.lo; v <= g.hi; v++ {
+			matches := g.reroll.matches(v)
+			switch {
+			// Rerolling until there isn't a match is the same as picking from the faces that don't match
+			case !g.rerollOnce && !matches:
+				first[v] = 1 / float64(sides-rerolled)
+			case g.rerollOnce && matches:
+				first[v] = float64(rerolled) / float64(sides) / float64(sides)
+			case g.rerollOnce:
+				first[v] = (1 + float64(rerolled)/float64(sides)) / float64(sides)
+			}
+		}
+	}
+	if g.explode == nil {
+		return first
+	}
+	// Work back from the last explosion as with dieOutcomes
+	values := all
+	for x := 1; x <= maxExplosions; x++ {
+		roll := all
+		if x == maxExplosions {
+			roll = first
+		}
+		next := diceDist{}
+		for v, p := range roll {
+			if !g.explode.matches(v) {
+				next[v] += p
+				continue
+			}
+			for total, tp := range values {
+				if p*tp >= minChance {
+					next[v+total] += p * tp
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func (g groupNode) distribution() (diceDist, error) {
+	die := g.dieDistribution()
+	if g.keep == "" || g.kept() == g.count {
+		return die.times(g.count)
+	}
+	// Keeping and dropping depends on every die so each combination is looked at
+	combinations := 1
+	for x := 0; x < g.count; x++ {
+		combinations *= len(die)
+		if combinations > maxCombinations {
+			return nil, errors.New("too many combinations to compute")
+		}
+	}
+	faces := make([]int, 0, len(die))
+	for v := range die {
+		faces = append(faces, v)
+	}
+	sort.Ints(faces)
+	dist := diceDist{}
+	rolls := make([]int, g.count)
+	var walk func(i int, p float64)
+	walk = func(i int, p float64) {
+		if i == g.count {
+			sorted := append([]int{}, rolls...)
+			sort.Ints(sorted)
+			kept := g.kept()
+			switch g.keep {
+			case "kh", "dl":
+				sorted = sorted[g.count-kept:]
+			default:
+				sorted = sorted[:kept]
+			}
+			total := 0
+			for _, v := range sorted {
+				total += v
+			}
+			dist[total] += p
+			return
+		}
+		for _, v := range faces {
+			rolls[i] = v
+			walk(i+1, p*die[v])
+		}
+	}
+	walk(0, 1)
+	return dist, nil
+}
+
+type diceParser struct {
+	input   string
+	pos     int
+	sawDice bool
+}
+
+func (p *diceParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *diceParser) accept(s string) bool {
+	if strings.HasPrefix(p.input[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *diceParser) expr() (diceNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '+' || p.peek() == '-' {
+		op := p.peek()
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *diceParser) term() (diceNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '*' || p.peek() == '/' {
+		op := p.peek()
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *diceParser) factor() (diceNode, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		node, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{node: node}, nil
+	case '(':
+		p.pos++
+		node, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("missing )")
+		}
+		return node, nil
+	}
+	count, hasCount, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 'd' {
+		if !hasCount {
+			if p.pos >= len(p.input) {
+				return nil, errors.New("unexpected end")
+			}
+			return nil, fmt.Errorf("unexpected %q", p.input[p.pos:])
+		}
+		return constNode{value: count}, nil
+	}
+	p.pos++
+	if !hasCount {
+		count = 1
+	}
+	return p.group(count)
+}
+
+func (p *diceParser) number() (int, bool, error) {
+	start := p.pos
+	for p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(p.input[start:p.pos])
+	if err != nil {
+		return 0, false, fmt.Errorf("%s is too large", p.input[start:p.pos])
+	}
+	return n, true, nil
+}
+
+// group parses the sides and modifiers of a group of dice. The d has already been read
+func (p *diceParser) group(count int) (diceNode, error) {
+	p.sawDice = true
+	if count > maxDiceCount {
+		return nil, fmt.Errorf("can't roll more than %d dice at once", maxDiceCount)
+	}
+	g := groupNode{count: count, lo: 1}
+	switch {
+	case p.accept("%"):
+		g.hi = 100
+	case p.accept("f"):
+		g.lo, g.hi = -1, 1
+	default:
+		sides, ok, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("missing number of sides")
+		}
+		if sides < 1 {
+			return nil, errors.New("sides must be 1 or more")
+		}
+		if sides > maxDiceSides {
+			return nil, fmt.Errorf("dice can't have more than %d sides", maxDiceSides)
+		}
+		g.hi = sides
+	}
+	for {
+		switch {
+		case p.accept("kl"):
+			g.keep = "kl"
+		case p.accept("kh"), p.accept("k"):
+			g.keep = "kh"
+		case p.accept("dh"):
+			g.keep = "dh"
+		case p.accept("dl"):
+			g.keep = "dl"
+		case p.peek() == 'd' && p.pos+1 < len(p.input) && p.input[p.pos+1] >= '0' && p.input[p.pos+1] <= '9':
+			p.pos++
+			g.keep = "dl"
+		case p.accept("!"):
+			cp, err := p.comparePoint(comparePoint{op: '=', value: g.hi})
+			if err != nil {
+				return nil, err
+			}
+			if cp.faces(g.lo, g.hi).size() == g.hi-g.lo+1 {
+				return nil, errors.New("dice would explode on every roll")
+			}
+			g.explode = &cp
+			continue
+		case p.accept("ro"), p.accept("r"):
+			g.rerollOnce = p.input[p.pos-1] == 'o'
+			cp, err := p.comparePoint(comparePoint{op: '=', value: g.lo})
+			if err != nil {
+				return nil, err
+			}
+			if !g.rerollOnce && cp.faces(g.lo, g.hi).size() == g.hi-g.lo+1 {
+				return nil, errors.New("dice would be rerolled on every roll")
+			}
+			g.reroll = &cp
+			continue
+		default:
+			return g, nil
+		}
+		// Keep and drop need the number of dice
+		n, ok, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing number of dice for %s", g.keep)
+		}
+		g.keepCount = n
+	}
+}
+
+// comparePoint parses an optional compare point, returning the default if there isn't one
+func (p *diceParser) comparePoint(def comparePoint) (comparePoint, error) {
+	cp := comparePoint{op: '='}
+	hasOp := false
+	if p.peek() == '<' || p.peek() == '>' || p.peek() == '=' {
+		cp.op = p.peek()
+		p.pos++
+		hasOp = true
+	}
+	n, ok, err := p.number()
+	if err != nil {
+		return cp, err
+	}
+	if !ok {
+		if hasOp {
+			return cp, errors.New("missing number to compare to")
+		}
+		return def, nil
+	}
+	cp.value = n
+	return cp, nil
+}
+
+// rangeSet is a sorted list of non-overlapping, non-adjacent ranges
+type rangeSet []Range
+
+func newRangeSet(values []int) rangeSet {
+	ranges := make([]Range, len(values))
+	for i, v := range values {
+		ranges[i] = Range{v, v}
+	}
+	return normalize(ranges)
+}
+
+// normalize sorts and merges ranges that overlap or touch
+func normalize(ranges []Range) rangeSet {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Min < ranges[j].Min })
+	set := rangeSet{}
+	for _, r := range ranges {
+		if r.Min > r.Max {
+			continue
+		}
+		if len(set) > 0 && r.Min <= set[len(set)-1].Max+1 {
+			if r.Max > set[len(set)-1].Max {
+				set[len(set)-1].Max = r.Max
+			}
+			continue
+		}
+		set = append(set, r)
+	}
+	return set
+}
+
+func (s rangeSet) size() int {
+	size := 0
+	for _, r := range s {
+		size += r.Max - r.Min + 1
+	}
+	return size
+}
+
+func (s rangeSet) values() []int {
+	values := []int{}
+	for _, r := range s {
+		for v := r.Min; v <= r.Max; v++ {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (s rangeSet) contains(v int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].Max >= v })
+	return i < len(s) && s[i].Min <= v
+}
+
+func (s rangeSet) negate() rangeSet {
+	ranges := make([]Range, len(s))
+	for i, r := range s {
+		ranges[i] = Range{-r.Max, -r.Min}
+	}
+	return normalize(ranges)
+}
+
+func (s rangeSet) add(o rangeSet) rangeSet {
+	ranges := []Range{}
+	for _, a := range s {
+		for _, b := range o {
+			ranges = append(ranges, Range{a.Min + b.Min, a.Max + b.Max})
+		}
+	}
+	return normalize(ranges)
+}
+
+// times returns every sum of n values from the set
+func (s rangeSet) times(n int) rangeSet {
+	result := rangeSet{{0, 0}}
+	for n > 0 {
+		if n%2 == 1 {
+			result = result.add(s)
+		}
+		s = s.add(s)
+		n /= 2
+	}
+	return result
+}
+
+func (s rangeSet) union(o rangeSet) rangeSet {
+	ranges := append(append([]Range{}, s...), o...)
+	return normalize(ranges)
+}
+
+func (s rangeSet) intersect(o rangeSet) rangeSet {
+	ranges := []Range{}
+	for _, a := range s {
+		for _, b := range o {
+			lo, hi := a.Min, a.Max
+			if b.Min > lo {
+				lo = b.Min
+			}
+			if b.Max < hi {
+				hi = b.Max
+			}
+			ranges = append(ranges, Range{lo, hi})
+		}
+	}
+	return normalize(ranges)
+}
+
+func (s rangeSet) subtract(o rangeSet) rangeSet {
+	result := s
+	for _, b := range o {
+		ranges := []Range{}
+		for _, a := range result {
+			ranges = append(ranges, Range{a.Min, min(a.Max, b.Min-1)}, Range{max(a.Min, b.Max+1), a.Max})
+		}
+		result = normalize(ranges)
+	}
+	return result
+}
+
+func (s rangeSet) clamp(lo, hi int) rangeSet {
+	return s.intersect(rangeSet{{lo, hi}})
+}
+
+// diceDist is the chance of each total
+type diceDist map[int]float64
+
+func (d diceDist) add(o diceDist) (diceDist, error) {
+	if len(d)*len(o) > maxOutcomePairs*10 {
+		return nil, errors.New("too many outcomes to compute")
+	}
+	dist := diceDist{}
+	for a, ap := range d {
+		for b, bp := range o {
+			dist[a+b] += ap * bp
+		}
+	}
+	return dist, nil
+}
+
+// times returns the chance of each sum of n rolls
+func (d diceDist) times(n int) (diceDist, error) {
+	result := diceDist{0: 1}
+	var err error
+	for n > 0 {
+		if n%2 == 1 {
+			if result, err = result.add(d); err != nil {
+				return nil, err
+			}
+		}
+		n /= 2
+		if n > 0 {
+			if d, err = d.add(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package randomtable
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type DiceOutcomeTest struct {
+	dicestr  string
+	expected []Range
+}
+
+func TestDiceOutcomes(t *testing.T) {
+	cases := []DiceOutcomeTest{
+		{dicestr: "1d6", expected: []Range{{1, 6}}},
+		{dicestr: "2d10", expected: []Range{{2, 20}}},
+		{dicestr: "d100", expected: []Range{{1, 100}}},
+		{dicestr: "d%", expected: []Range{{1, 100}}},
+		{dicestr: "1d6+1", expected: []Range{{2, 7}}},
+		{dicestr: "2d6-2", expected: []Range{{0, 10}}},
+		{dicestr: "4d6kh3", expected: []Range{{3, 18}}},
+		{dicestr: "4d6k3", expected: []Range{{3, 18}}},
+		{dicestr: "2d20kl1", expected: []Range{{1, 20}}},
+		{dicestr: "4d6d1", expected: []Range{{3, 18}}},
+		{dicestr: "4dF", expected: []Range{{-4, 4}}},
+		{dicestr: "1d6r<2", expected: []Range{{3, 6}}},
+		{dicestr: "1d6ro1", expected: []Range{{1, 6}}},
+		{dicestr: "1d6+1d4", expected: []Range{{2, 10}}},
+		{dicestr: "1d4*10", expected: []Range{{10, 10}, {20, 20}, {30, 30}, {40, 40}}},
+		{dicestr: "(1d6+1)/2", expected: []Range{{1, 3}}},
+		{dicestr: "1d2!", expected: []Range{{1, 1}, {3, 3}, {5, 5}}},
+	}
+	for _, c := range cases {
+		d, err := ParseDice(c.dicestr)
+		if err != nil {
+			t.Errorf("%s: %v", c.dicestr, err)
+			continue
+		}
+		actual, err := d.Outcomes()
+		if err != nil {
+			t.Errorf("%s: %v", c.dicestr, err)
+			continue
+		}
+		// Exploding dice can go on for a long time, only check the start
+		if len(actual) > len(c.expected) {
+			actual = actual[:len(c.expected)]
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: Expected %v but got %v", c.dicestr, c.expected, actual)
+		}
+		// Every roll should be one of the outcomes
+		all, _ := d.Outcomes()
+		r := rand.New(rand.NewSource(1))
+		for x := 0; x < 200; x++ {
+			result, err := d.Roll(r)
+			if err != nil {
+				t.Errorf("%s: %v", c.dicestr, err)
+			}
+			if !rangeSet(all).contains(result.Int()) {
+				t.Errorf("%s: rolled %v which isn't an outcome", c.dicestr, result.Int())
+			}
+		}
+	}
+}
+
+func TestBadDice(t *testing.T) {
+	cases := []string{"", "d", "2d", "Treasure", "1d0", "10", "1d6+", "1d1!", "1d6r<6", "2d6kh", "(1d6", "9999999999999d6", "100001d6", "1d1000001"}
+	for _, c := range cases {
+		if _, err := ParseDice(c); err == nil {
+			t.Errorf("Expected %q to fail parsing", c)
+		}
+	}
+}
+
+func TestKeepDice(t *testing.T) {
+	d, _ := ParseDice("4d6kh3")
+	result, err := d.Roll(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rolls) != 3 || len(result.Dropped) != 1 {
+		t.Errorf("Expected 3 kept and 1 dropped but got %v", result)
+	}
+	for _, kept := range result.Rolls {
+		if kept < result.Dropped[0] {
+			t.Errorf("Dropped a higher die: %v", result)
+		}
+	}
+}
+
+func TestDiceDistribution(t *testing.T) {
+	cases := map[string]map[int]float64{
+		"2d6":     {2: 1.0 / 36, 7: 6.0 / 36, 12: 1.0 / 36},
+		"2d20kh1": {1: 1.0 / 400, 20: 39.0 / 400},
+		"1d6ro1":  {1: 1.0 / 36, 6: 7.0 / 36},
+		"1d4r1":   {1: 0, 2: 1.0 / 3},
+		"1d2!":    {1: 0.5, 3: 0.25},
+		"dF":      {-1: 1.0 / 3, 0: 1.0 / 3},
+	}
+	for dicestr, expected := range cases {
+		d, err := ParseDice(dicestr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dist, err := d.Distribution()
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0.0
+		for _, p := range dist {
+			total += p
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("%s: chances add up to %v", dicestr, total)
+		}
+		for roll, chance := range expected {
+			if math.Abs(dist[roll]-chance) > 1e-9 {
+				t.Errorf("%s: Expected %v to have a chance of %v but got %v", dicestr, roll, chance, dist[roll])
+			}
+		}
+	}
+}
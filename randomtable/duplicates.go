@@ -0,0 +1,187 @@
+package randomtable
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// DuplicatePolicy decides what happens when a table is added with a name that is already in the tree
+type DuplicatePolicy int
+
+const (
+	// DuplicateLastWins replaces the existing table with the new one
+	DuplicateLastWins DuplicatePolicy = iota
+	// DuplicateFirstWins keeps the existing table and ignores the new one
+	DuplicateFirstWins
+	// DuplicateMergeRows adds the rows of the new table to the existing table when both
+	// are the same kind of table. Otherwise the new table replaces the existing one.
+	DuplicateMergeRows
+	// DuplicateError fails to load the new table
+	DuplicateError
+)
+
+var duplicatePolicyNames = []string{"last-wins", "first-wins", "merge-rows", "error"}
+
+func (p DuplicatePolicy) String() string {
+	if p < 0 || int(p) >= len(duplicatePolicyNames) {
+		return fmt.Sprintf("duplicatepolicy(%d)", int(p))
+	}
+	return duplicatePolicyNames[p]
+}
+
+// ParseDuplicatePolicy returns the policy with the given name
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	for i, n := range duplicatePolicyNames {
+		if strings.EqualFold(name, n) {
+			return DuplicatePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown duplicate policy %q, use one of %s", name, strings.Join(duplicatePolicyNames, ", "))
+}
+
+// WithDuplicatePolicy returns a tree that shares tables with this one and uses the given
+// policy when a table is added with a name that is already in the tree
+func (t Tree) WithDuplicatePolicy(policy DuplicatePolicy) Tree {
+	t.duplicates = policy
+	return t
+}
+
+// addTable adds a table that was found at the given source. It returns the table that items
+// should be added to, which is a copy of the existing table when rows are merged. The
+// existing table may be shared with other trees, such as the tree being served while a file
+// is reloaded, so it's never changed. An error is returned when the duplicate policy doesn't
+// allow the table.
+func (t *Tree) addTable(name string, table Table, hidden bool, source Source) (Table, error) {
+	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+	existing := t.tables.Get(name)
+	if existing != nil {
+		if existingNode, ok := existing.(TableNode); ok && t.duplicates == DuplicateMergeRows && canMerge(existingNode.Table, table) {
+			t.addDuplicateIssue(name, SeverityInfo, source, "rows are merged into the table from %s", sourceOf(existing))
+			existingNode.Table = cloneTable(existingNode.Table)
+			t.tables.Put(name, existingNode)
+			return existingNode.Table, nil
+		}
+		if keep, err := t.keepExisting(name, existing, source); keep {
+			return table, err
+		}
+	}
+	t.tables.Put(name, TableNode{Table: table, Hidden: hidden, Source: source})
+	return table, nil
+}
+
+// addLink adds a link that was found at the given source. Links can't be merged so they
+// replace the existing table when rows would be merged.
+func (t *Tree) addLink(name, link string, source Source) error {
+	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+	node, err := newLinkNode(link, source)
+	if err != nil {
+		return fmt.Errorf("link %s at %s: %w", name, source, err)
+	}
+	if existing := t.tables.Get(name); existing != nil {
+		if keep, err := t.keepExisting(name, existing, source); keep {
+			return err
+		}
+	}
+	t.tables.Put(name, node)
+	return nil
+}
+
+// newLinkNode creates a link to a table. Arguments for the table can be given after the
+// name of the table as a query, such as npc?race=elf&level=3.
+func newLinkNode(link string, source Source) (LinkNode, error) {
+	table, query, found := strings.Cut(link, "?")
+	node := LinkNode{Link: table, Source: source}
+	if !found {
+		return node, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return node, fmt.Errorf("invalid arguments %q: %w", query, err)
+	}
+	node.Args = map[string]string{}
+	for k, v := range values {
+		node.Args[k] = v[len(v)-1]
+	}
+	return node, nil
+}
+
+// keepExisting applies the duplicate policy to a name that is already in the tree.
+// It returns true when the existing table should be kept.
+func (t *Tree) keepExisting(name string, existing interface{}, source Source) (bool, error) {
+	switch t.duplicates {
+	case DuplicateError:
+		t.addDuplicateIssue(name, SeverityError, source, "the table is already defined at %s", sourceOf(existing))
+		return true, fmt.Errorf("%s is defined at both %s and %s", name, sourceOf(existing), source)
+	case DuplicateFirstWins:
+		t.addDuplicateIssue(name, SeverityWarning, source, "duplicate table entered, the table from %s is kept", sourceOf(existing))
+		return true, nil
+	case DuplicateMergeRows:
+		t.addDuplicateIssue(name, SeverityWarning, source, "duplicate table entered, it is a different kind of table so it replaces the table from %s", sourceOf(existing))
+		return false, nil
+	default:
+		t.addDuplicateIssue(name, SeverityWarning, source, "duplicate table entered, it replaces the table from %s", sourceOf(existing))
+		return false, nil
+	}
+}
+
+func (t *Tree) addDuplicateIssue(name string, severity Severity, source Source, format string, args ...interface{}) {
+	issue := newIssue(severity, IssueDuplicateTable, format, args...)
+	issue.Table = name
+	issue.File = source.File
+	issue.Line = source.Line
+	*t.loadIssues = append(*t.loadIssues, issue)
+}
+
+// sourceOf returns where a node in the tree was found
+func sourceOf(node interface{}) Source {
+	switch n := node.(type) {
+	case TableNode:
+		return n.Source
+	case LinkNode:
+		return n.Source
+	}
+	return Source{}
+}
+
+// cloneTable copies a table so that rows can be added to the copy without changing the
+// original. Tables from outside this package can't be copied and are returned as they are.
+func cloneTable(table Table) Table {
+	switch tb := table.(type) {
+	case *RandomTable:
+		c := *tb
+		c.items = append([]string{}, tb.items...)
+		c.rowSources = append(rowSources{}, tb.rowSources...)
+		return &c
+	case *WeightedTable:
+		c := tb.clone()
+		return &c
+	case *DeckTable:
+		return &DeckTable{WeightedTable: tb.clone()}
+	case *RollingTable:
+		c := *tb
+		c.rows = append([]rollInterval{}, tb.rows...)
+		c.intervals = append([]rollInterval{}, tb.intervals...)
+		c.replaced = append([]replacedRolls{}, tb.replaced...)
+		c.rowSources = append(rowSources{}, tb.rowSources...)
+		return &c
+	case *TextTable:
+		c := *tb
+		c.rowSources = append(rowSources{}, tb.rowSources...)
+		return &c
+	}
+	return table
+}
+
+// canMerge is true when the rows of table can be added to existing. Rolling tables
+// also need to use the same dice.
+func canMerge(existing, table Table) bool {
+	if reflect.TypeOf(existing) != reflect.TypeOf(table) {
+		return false
+	}
+	if rt, ok := existing.(*RollingTable); ok {
+		return rt.dicestr == table.(*RollingTable).dicestr
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package randomtable
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadFiles(tree Tree, files map[string]string, order []string) error {
+	for _, file := range order {
+		md := NewMarkdownParser(tree.WithFile(file))
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(files[file]), &buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	files := map[string]string{
+		"a.md": "| Color | Size |\n| --- | --- |\n| Red | Big |\n",
+		"b.md": "\n| Color |\n| --- |\n| Blue |\n",
+	}
+	order := []string{"a.md", "b.md"}
+	cases := []struct {
+		policy   DuplicatePolicy
+		expected []string
+		columns  []string
+	}{
+		{policy: DuplicateLastWins, expected: []string{"Blue"}, columns: []string{"Color"}},
+		{policy: DuplicateFirstWins, expected: []string{"Red"}, columns: []string{"Color", "Size"}},
+		{policy: DuplicateMergeRows, expected: []string{"Red", "Blue"}, columns: []string{"Color", "Size"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			tree := NewTree().WithDuplicatePolicy(tc.policy)
+			if err := loadFiles(tree, files, order); err != nil {
+				t.Fatal(err)
+			}
+			tb, _, err := tree.GetTable("color")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tb.AllItems(), tc.expected) {
+				t.Errorf("Expected %v but got %v", tc.expected, tb.AllItems())
+			}
+			if tb.Row == nil || !reflect.DeepEqual(tb.Row.Headers(), tc.columns) {
+				t.Errorf("Expected the rows of the table to have columns %v", tc.columns)
+			}
+			issues := tree.Validate()
+			if len(issues) != 1 || issues[0].Kind != IssueDuplicateTable {
+				t.Fatalf("Expected a duplicate table issue but got %v", issues)
+			}
+			if issues[0].File != "b.md" || issues[0].Line != 2 || !strings.Contains(issues[0].Message, "a.md:1") {
+				t.Errorf("Expected the issue to name both files but got %v", issues[0])
+			}
+		})
+	}
+	tree := NewTree().WithDuplicatePolicy(DuplicateError)
+	err := loadFiles(tree, files, order)
+	if err == nil || !strings.Contains(err.Error(), "a.md:1") || !strings.Contains(err.Error(), "b.md:2") {
+		t.Errorf("Expected an error naming both files but got %v", err)
+	}
+}
+
+func TestAddTable(t *testing.T) {
+	tree := NewTree().WithDuplicatePolicy(DuplicateMergeRows)
+	first := NewRandomTable()
+	first.AddItem("Red")
+	if _, err := tree.AddTable("Color", &first, false); err != nil {
+		t.Fatal(err)
+	}
+	second := NewRandomTable()
+	added, err := tree.AddTable("Color", &second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added.AddItem("Blue")
+	tb, _, err := tree.GetTable("color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tb.AllItems(), []string{"Red", "Blue"}) {
+		t.Errorf("Expected items added to the returned table to be merged but got %v", tb.AllItems())
+	}
+
+	strict := NewTree().WithDuplicatePolicy(DuplicateError)
+	if _, err := strict.AddTable("color", &first, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.AddTable("color", &second, false); err == nil {
+		t.Error("Expected an error adding a duplicate table")
+	}
+	if err := strict.AddLink("color", "shade"); err == nil {
+		t.Error("Expected an error adding a duplicate link")
+	}
+}
+
+const sourceTest = "# Places\n" +
+	"\n" +
+	"| Town |\n" +
+	"| ---- |\n" +
+	"| Bree |\n" +
+	"| Dale |\n" +
+	"\n" +
+	"River\n" +
+	": Anduin\n" +
+	": Celduin\n" +
+	"\n" +
+	"```sign\n" +
+	"Welcome\n" +
+	"```\n" +
+	"\n" +
+	"[city](places/town)\n"
+
+func TestSources(t *testing.T) {
+	tree := NewTree().WithFile("places.md")
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(sourceTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Source{
+		"places/town":  {File: "places.md", Line: 3, EndLine: 6},
+		"places/river": {File: "places.md", Line: 8, EndLine: 10},
+		"places/sign":  {File: "places.md", Line: 12, EndLine: 14},
+		"places/city":  {File: "places.md", Line: 16, EndLine: 16},
+	}
+	for name, source := range expected {
+		if got := sourceOf(tree.tables.Get(name)); got != source {
+			t.Errorf("Expected %s to be at %+v but got %+v", name, source, got)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package randomtable
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -367,6 +370,37 @@ List
 				"one", "two",
 			},
 		},
+		{
+			table: `
+| Monster | weight |
+| ------- | ------ |
+| Goblin  | 5      |
+| Orc     | 3      |
+| Dragon  | 0      |
+`,
+			name:      "Test weighted table",
+			tablePath: "monster",
+			expected: []string{
+				"Goblin", "Orc",
+			},
+		},
+		{
+			table: `
+| t1     | weight |
+| ------ | ------ |
+| one    | 2      |
+| two    | 1      |
+
+| t2                   |
+| -------------------- |
+| {{fudge "t1" "1d1+2"}} |
+`,
+			name:      "Test fudge works on a weighted table",
+			tablePath: "t2",
+			expected: []string{
+				"two",
+			},
+		},
 	}
 	for _, tc := range tests {
 		tree := NewTree()
@@ -375,7 +409,7 @@ List
 		if err := md.Convert(bytes.NewBufferString(tc.table).Bytes(), &buf); err != nil {
 			t.Error(err)
 		}
-		actual, err := tree.GetItem(tc.tablePath)
+		actual, err := tree.GetItem(context.Background(), tc.tablePath)
 		if err != nil {
 			t.Errorf("Test: %v, Error: %v. Found: %v", tc.name, err, tree.ListTables("", true))
 		}
@@ -492,7 +526,7 @@ func TestHtmlFormattedTables(t *testing.T) {
 			tablePath: "t1",
 			name:      "Test Html gets formatted properly",
 			expected: []string{
-				"<RandomElement table='t1'>one</RandomElement>",
+				`<RandomElement table="t1" data-row="1">one</RandomElement>`,
 			},
 		},
 		{
@@ -500,7 +534,7 @@ func TestHtmlFormattedTables(t *testing.T) {
 			tablePath: "t1",
 			name:      "Test Nested Html gets formatted properly",
 			expected: []string{
-				"<RandomElement table='t1'>one: <RandomElement table='t2'>two</RandomElement></RandomElement>",
+				`<RandomElement table="t1" data-row="1">one: <RandomElement table="t2" data-row="1">two</RandomElement></RandomElement>`,
 			},
 		},
 	}
@@ -511,7 +545,7 @@ func TestHtmlFormattedTables(t *testing.T) {
 		if err := md.Convert(bytes.NewBufferString(tc.table).Bytes(), &buf); err != nil {
 			t.Error(err)
 		}
-		actual, _ := tree.GetItem(tc.tablePath)
+		actual, _ := tree.GetItem(context.Background(), tc.tablePath)
 		if !reflect.DeepEqual([]string{actual}, tc.expected) {
 			t.Errorf("%s: Expected to find %s but got %s.", tc.name, tc.expected, actual)
 		}
@@ -566,3 +600,615 @@ const linkTest = `
 |t2|
 |---|
 `
+
+const deckTest = `
+| Loot   | deck |
+| ------ | ---- |
+| Sword  |      |
+| Shield | 1    |
+| Coin   | 2    |
+
+| Hand                                                  |
+| ----------------------------------------------------- |
+| {{draw "loot" 2}} {{remaining "loot"}}                |
+
+| Reset                                                     |
+| --------------------------------------------------------- |
+| {{draw "loot"}}{{shuffleDeck "loot"}} {{remaining "loot"}} |
+
+| Letters                  |
+| ------------------------ |
+| {{shuffle "abcdef"}}     |
+
+| Coin    |
+| ------- |
+| Heads   |
+| Tails   |
+
+| Flips                                                        |
+| ------------------------------------------------------------ |
+| {{norepeat "coin" 1}} {{norepeat "coin" 1}} {{norepeat "coin" 1}} |
+`
+
+func TestDecks(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(deckTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	// Every card is dealt once before the deck is reshuffled
+	for round := 0; round < 2; round++ {
+		dealt := []string{}
+		for x := 0; x < 4; x++ {
+			item, err := tree.GetItem(context.Background(), "loot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dealt = append(dealt, item)
+		}
+		sort.Strings(dealt)
+		expected := []string{"Coin", "Coin", "Shield", "Sword"}
+		if !reflect.DeepEqual(dealt, expected) {
+			t.Errorf("Expected to deal %v but got %v", expected, dealt)
+		}
+	}
+
+	session := tree.WithNewSession()
+	hand, err := session.GetItem(context.Background(), "hand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(hand, " 2") {
+		t.Errorf("Expected two cards to remain but got %s", hand)
+	}
+	reset, err := session.GetItem(context.Background(), "reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset[len(reset)-1:] != "4" {
+		t.Errorf("Expected shuffleDeck to return all cards but got %s", reset)
+	}
+	// sprig's shuffle still shuffles strings
+	letters, err := session.GetItem(context.Background(), "letters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted := strings.Split(letters, "")
+	sort.Strings(sorted)
+	if strings.Join(sorted, "") != "abcdef" {
+		t.Errorf("Expected the letters to be shuffled but got %s", letters)
+	}
+
+	flips, err := session.GetItem(context.Background(), "flips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flips != "Heads Tails Heads" && flips != "Tails Heads Tails" {
+		t.Errorf("Expected flips to alternate but got %s", flips)
+	}
+}
+
+func TestDeckCopies(t *testing.T) {
+	deck := NewDeckTable()
+	deck.AddCard("Coin", "2000000000")
+	deck.AddCard("Gem", "2")
+	if cards := deck.Cards(); len(cards) != maxCopies+2 {
+		t.Errorf("Expected the coins to stop at %d copies but got %d cards", maxCopies, len(cards))
+	}
+	issues := deck.Validate()
+	if len(issues) != 1 || issues[0].Kind != IssueInvalidWeight || !strings.Contains(issues[0].Message, "copies") {
+		t.Errorf("Expected an error for the copies over the limit but got %v", issues)
+	}
+}
+
+const markerTest = `
+| Item  | Deck   |
+| ----- | ------ |
+| Cards | Upper  |
+
+| Sack  | Weight |
+| ----- | ------ |
+| Rope  | 2      |
+
+| Gear  | weight |
+| ----- | ------ |
+| Torch | 2      |
+| Lamp  | lots   |
+
+| Prize | deck |
+| ----- | ---- |
+| Cup   |      |
+
+# Other
+
+| Thing | deck |
+| ----- | ---- |
+| Box   | Blue |
+`
+
+func TestMarkerColumns(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(markerTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		table string
+		kind  TableKind
+	}{
+		// Only the lowercase header holding numbers marks a column
+		{table: "item", kind: KindRandom},
+		{table: "deck", kind: KindRandom},
+		{table: "other/thing", kind: KindRandom},
+		{table: "other/deck", kind: KindRandom},
+		{table: "sack", kind: KindRandom},
+		{table: "weight", kind: KindRandom},
+		{table: "gear", kind: KindWeighted},
+		{table: "prize", kind: KindDeck},
+	}
+	for _, tc := range tests {
+		desc, err := tree.Describe(tc.table)
+		if err != nil {
+			t.Errorf("%s: %v", tc.table, err)
+			continue
+		}
+		if desc.Kind != tc.kind {
+			t.Errorf("%s: expected a %s table but got %s", tc.table, tc.kind, desc.Kind)
+		}
+	}
+	if item, err := tree.GetItem(context.Background(), "deck"); err != nil || item != "Upper" {
+		t.Errorf("Expected the deck column to be its own table but got %q %v", item, err)
+	}
+}
+
+func TestNoRepeatCounts(t *testing.T) {
+	decks := newDeckStore()
+	for _, n := range []int{-1, 0} {
+		decks.remember("coin", "Heads", n)
+		if decks.recent("coin", "Heads", n) {
+			t.Errorf("Expected nothing to be recent with %d results", n)
+		}
+	}
+
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	tables := "| Empty |\n| ----- |\n\n| Never |\n| ----- |\n| {{norepeat \"empty\" 1}} |\n\n| Back |\n| ---- |\n| {{norepeat \"coin\" -2}} |\n\n| Coin |\n| ---- |\n| Heads |\n"
+	if err := md.Convert([]byte(tables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.GetItem(context.Background(), "never"); !errors.Is(err, ErrEmptyTable) {
+		t.Error("Expected an error from norepeat on an empty table")
+	}
+	if back, err := tree.GetItem(context.Background(), "back"); err != nil || back != "Heads" {
+		t.Errorf("Expected a negative count to allow repeats but got %q %v", back, err)
+	}
+}
+
+const rowTest = `
+# Bestiary
+
+| 1d4 | Monster | HP | AC |
+| --- | ------- | -- | -- |
+| 1-2 | Goblin  | 7  | 15 |
+| 3   | Orc     | 15 | 13 |
+| 4   | Ogre    | 59 | 11 |
+
+| Stats                                                               |
+| ------------------------------------------------------------------- |
+| {{$m := lookupRow "./monster"}}{{$m.Monster}} HP:{{$m.HP}} AC:{{$m.AC}} |
+`
+
+func TestRows(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(rowTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	expected := map[string]Row{
+		"Goblin": {"Monster": "Goblin", "HP": "7", "AC": "15"},
+		"Orc":    {"Monster": "Orc", "HP": "15", "AC": "13"},
+		"Ogre":   {"Monster": "Ogre", "HP": "59", "AC": "11"},
+	}
+	for x := 0; x < 20; x++ {
+		// Any column of the table can be used to find the row
+		row, columns, err := tree.GetRow(context.Background(), "bestiary/ac")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(columns, []string{"Monster", "HP", "AC"}) {
+			t.Errorf("Unexpected columns: %v", columns)
+		}
+		if !reflect.DeepEqual(row, expected[row["Monster"]]) {
+			t.Errorf("Row did not match: %v", row)
+		}
+		stats, err := tree.GetItem(context.Background(), "bestiary/stats")
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, r := range expected {
+			if stats == fmt.Sprintf("%s HP:%s AC:%s", r["Monster"], r["HP"], r["AC"]) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Stats did not come from a single row: %s", stats)
+		}
+	}
+	if _, _, err := tree.GetRow(context.Background(), "bestiary/stats"); err != nil {
+		t.Errorf("Single column tables should have rows: %v", err)
+	}
+}
+
+const seedTest = `
+| 1d20 | Roll |
+| ---- | ---- |
+| 1-20 | {{roll "3d6"}} {{pick "a" "b" "c"}} {{chance 0.5 "no" "yes"}} |
+
+| Name   |
+| ------ |
+| Ann    |
+| Bob    |
+| Cid    |
+
+| Card | deck |
+| ---- | ---- |
+| Ace  | 2    |
+| King | 2    |
+
+| All                                                       |
+| --------------------------------------------------------- |
+| {{lookup "roll"}} {{lookup "name"}} {{draw "card" 3}} {{fudge "name" "1d2"}} {{lookup "sprig"}} |
+
+| Sprig                                                     |
+| --------------------------------------------------------- |
+| {{randAlpha 6}} {{randAlphaNum 6}} {{randNumeric 6}} {{randAscii 6}} {{uuidv4}} {{shuffle "abcdef"}} |
+`
+
+func TestSeededTrees(t *testing.T) {
+	generate := func(seed int64) []string {
+		tree := NewTree()
+		md := NewMarkdownParser(tree)
+		var buf bytes.Buffer
+		if err := md.Convert(bytes.NewBufferString(seedTest).Bytes(), &buf); err != nil {
+			t.Error(err)
+		}
+		tree.ValidateTables()
+		tree = tree.WithSeed(seed)
+		results := []string{}
+		for x := 0; x < 20; x++ {
+			item, err := tree.GetItem(context.Background(), "all")
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, item)
+		}
+		return results
+	}
+	first := generate(42)
+	if !reflect.DeepEqual(first, generate(42)) {
+		t.Error("The same seed should give the same results")
+	}
+	if reflect.DeepEqual(first, generate(7)) {
+		t.Error("Different seeds should give different results")
+	}
+}
+
+const traceTest = `
+| 1d6 | Monster                   |
+| --- | ------------------------- |
+| 1-6 | {{lookup "color"}} dragon |
+
+| Color |
+| ----- |
+| Red   |
+`
+
+func TestTrace(t *testing.T) {
+	tree := NewTree().WithStringFormatter()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(traceTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	item, trace, err := tree.GetItemWithTrace(context.Background(), "monster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "Red dragon" || trace.Result != item {
+		t.Errorf("Expected the trace to end with %q but got %q", item, trace.Result)
+	}
+	if trace.Table != "monster" || trace.Dice != "1d6" || trace.Roll == nil {
+		t.Errorf("Expected the roll on monster to be traced but got %+v", trace)
+	}
+	if len(trace.Children) != 1 {
+		t.Fatalf("Expected 1 nested lookup but got %d", len(trace.Children))
+	}
+	child := trace.Children[0]
+	if child.Function != "lookup" || child.Table != "color" || child.Result != "Red" || child.Row == nil || *child.Row != 0 {
+		t.Errorf("Expected the lookup on color to be traced but got %+v", child)
+	}
+}
+
+const cycleTest = `
+| Loop                 |
+| -------------------- |
+| {{lookup "around"}}  |
+
+| Around             |
+| ------------------ |
+| {{lookup "loop"}}  |
+
+| Fudged                    |
+| ------------------------- |
+| {{fudge "fudged" "1d1"}}  |
+
+# Links
+
+[first](links/second)
+
+[second](links/first)
+`
+
+func TestCycles(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(cycleTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	for _, table := range []string{"loop", "fudged"} {
+		_, err := tree.GetItem(context.Background(), table)
+		var depthErr *LookupDepthError
+		if !errors.As(err, &depthErr) {
+			t.Fatalf("Expected %s to exceed the lookup depth but got %v", table, err)
+		}
+		if len(depthErr.Chain) != tree.maxLookupDepth+1 || depthErr.Chain[0] != table {
+			t.Errorf("Expected the chain to start with %s but got %v", table, depthErr.Chain)
+		}
+	}
+	_, _, err := tree.GetTable("links/first")
+	if err == nil || !strings.Contains(err.Error(), "links/first -> links/second -> links/first") {
+		t.Errorf("Expected links that lead back to themselves to fail but got %v", err)
+	}
+	expected := [][]string{{"around", "loop", "around"}, {"fudged", "fudged"}}
+	if cycles := tree.lookupCycles(); !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected cycles %v but got %v", expected, cycles)
+	}
+}
+
+const argsTest = `
+# People
+
+| Npc                                                                      |
+| ------------------------------------------------------------------------ |
+| {{ default "human" .race }} {{ default "farmer" .job }} of level {{ .level }} |
+
+| Pair                                                                             |
+| -------------------------------------------------------------------------------- |
+| {{ lookup "./npc" "race" "elf" "level" 3 }} and {{ lookup "./elf" "job" .job }} |
+
+| Name | Title                    |
+| ---- | ------------------------ |
+| Ann  | {{ .race }} {{ .level }} |
+
+[elf](people/npc?race=elf&level=1&job=archer)
+[elder](people/elf?level=9)
+`
+
+func TestLookupArgs(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(argsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"people/npc":   "human farmer of level <no value>",
+		"people/elf":   "elf archer of level 1",
+		"people/elder": "elf archer of level 9",
+		// Lookups only pass on the arguments they are given
+		"people/pair": "elf farmer of level 3 and elf archer of level 1",
+	}
+	for table, expected := range cases {
+		item, trace, err := tree.GetItemWithTrace(context.Background(), table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item != expected {
+			t.Errorf("Expected %q from %s but got %q", expected, table, item)
+		}
+		if table == "people/elder" && !strings.Contains(trace.String(), "args: job=archer level=9 race=elf") {
+			t.Errorf("Expected the trace to show the arguments but got %s", trace)
+		}
+	}
+	tmpl := `{{ (lookupRow "people/name" "race" "dwarf" "level" 2).Title }}`
+	item, err := tree.renderItem(newGeneration(context.Background()), tmpl, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "dwarf 2" {
+		t.Errorf("Expected the row to be given the arguments but got %q", item)
+	}
+	if _, err := tree.renderItem(newGeneration(context.Background()), `{{ lookup "people/npc" "race" }}`, "", nil); err == nil {
+		t.Error("Expected an error for an argument without a value")
+	}
+}
+
+func TestTreeValues(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(argsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	valued := tree.WithValues(map[string]string{"level": "7", "job": "miner"})
+	cases := map[string]string{
+		"people/npc": "human miner of level 7",
+		// Links and lookups replace the values they give arguments for
+		"people/elf":  "elf archer of level 1",
+		"people/pair": "elf miner of level 3 and elf miner of level 1",
+	}
+	for table, expected := range cases {
+		item, err := valued.GetItem(context.Background(), table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item != expected {
+			t.Errorf("Expected %q from %s but got %q", expected, table, item)
+		}
+	}
+	row, _, err := valued.GetRow(context.Background(), "people/name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row["Title"] != "<no value> 7" {
+		t.Errorf("Expected the row to use the values but got %q", row["Title"])
+	}
+	// The tree the values were added to is left as it was
+	item, err := tree.GetItem(context.Background(), "people/npc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "human farmer of level <no value>" {
+		t.Errorf("Expected the original tree to have no values but got %q", item)
+	}
+}
+
+const tavernTest = `
+# Tavern
+
+| Tavern                                                               |
+| -------------------------------------------------------------------- |
+| {{ remember "owner" (lookup "./name") }} runs the inn. {{ lookup "./rumour" }} |
+
+| Rumour                                    |
+| ----------------------------------------- |
+| They say {{ recall "owner" }} is a spy     |
+
+| Regulars                                                                   |
+| -------------------------------------------------------------------------- |
+| {{ sticky "./name" }} drinks with {{ sticky "./name" }} and {{ lookup "./toast" }} |
+
+| Toast                |
+| -------------------- |
+| {{ sticky "./name" }} |
+
+| Name |
+| ---- |
+| Ann  |
+| Bob  |
+| Cid  |
+| Dee  |
+`
+
+func TestStickyKeys(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	tables := "# Keys\n\n| Both |\n| --- |\n| {{ sticky \"./npcrace\" }} {{ sticky \"./npc\" \"race\" \"\" }} |\n\n" +
+		"| NpcRace |\n| --- |\n| Elf |\n\n| Npc |\n| --- |\n| Ann |\n"
+	if err := md.Convert([]byte(tables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	item, err := tree.GetItem(context.Background(), "keys/both")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "Elf Ann" {
+		t.Errorf("Expected different tables to be looked up separately but got %s", item)
+	}
+}
+
+func TestGenerationMemory(t *testing.T) {
+	tree := NewTree().WithSeed(1)
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(tavernTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	owners := map[string]bool{}
+	regulars := map[string]bool{}
+	for x := 0; x < 20; x++ {
+		item, err := tree.GetItem(context.Background(), "tavern/tavern")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var owner, rumoured string
+		if _, err := fmt.Sscanf(item, "%s runs the inn. They say %s is a spy", &owner, &rumoured); err != nil {
+			t.Fatalf("Unexpected item %q: %v", item, err)
+		}
+		if owner != rumoured {
+			t.Errorf("Expected the rumour to recall %s but got %q", owner, item)
+		}
+		owners[owner] = true
+
+		item, err = tree.GetItem(context.Background(), "tavern/regulars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var first, second, third string
+		if _, err := fmt.Sscanf(item, "%s drinks with %s and %s", &first, &second, &third); err != nil {
+			t.Fatalf("Unexpected item %q: %v", item, err)
+		}
+		if first != second || first != third {
+			t.Errorf("Expected every sticky lookup to give the same name but got %q", item)
+		}
+		regulars[first] = true
+	}
+	// Each generation starts with nothing remembered
+	if len(owners) < 2 || len(regulars) < 2 {
+		t.Errorf("Expected different generations to give different names but got %v and %v", owners, regulars)
+	}
+	item, err := tree.GetItem(context.Background(), "tavern/rumour")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "They say <no value> is a spy" {
+		t.Errorf("Expected nothing to be recalled without remembering it but got %q", item)
+	}
+}
+
+const htmlTest = `
+# Html
+
+` + "```" + ` note
+<b>{{ .who }}</b> says {{ upper (lookup "./quote") }} {{ trusted "<br/>" }}
+` + "```" + `
+
+| 1d1 | Quote                        |
+| --- | ---------------------------- |
+| 1   | "a < b" & {{ pick "c" "c" }} |
+`
+
+func TestHtmlEscaping(t *testing.T) {
+	tree := NewTree().WithHtmlFormatter().WithValues(map[string]string{"who": "<i>Ann</i>"})
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(htmlTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	item, trace, err := tree.GetItemWithTrace(context.Background(), "html/note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<RandomElement table="html/note">&lt;b&gt;&lt;i&gt;Ann&lt;/i&gt;&lt;/b&gt; says ` +
+		`<RandomElement table="html/quote" data-dice="1d1" data-roll="1" data-row="1">&#34;A &lt; B&#34; &amp; C</RandomElement> <br/>` + "\n</RandomElement>"
+	if item != expected {
+		t.Errorf("Expected %s but got %s", expected, item)
+	}
+	if trace.Result != item {
+		t.Errorf("Expected the trace to have the finished item but got %s", trace.Result)
+	}
+	row, _, err := tree.GetRow(context.Background(), "html/quote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(row["Quote"], `>&#34;a &lt; b&#34; &amp; c</RandomElement>`) {
+		t.Errorf("Expected the row to be escaped but got %s", row["Quote"])
+	}
+}
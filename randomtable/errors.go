@@ -0,0 +1,66 @@
+package randomtable
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyTable is returned when a table has no items that can be picked, such as a table
+// without rows or a deck without cards
+var ErrEmptyTable = errors.New("table has no items that can be picked")
+
+// ErrNothingToPick is returned when pick isn't given any items
+var ErrNothingToPick = errors.New("pick needs at least one item to pick from")
+
+// ErrCannotModify is returned when a roll modifier is used on a deck
+var ErrCannotModify = errors.New("decks are dealt rather than rolled so they can't be given a roll modifier")
+
+// ErrNoRows is returned when a row is asked for from a table that isn't a column of a
+// markdown table
+var ErrNoRows = errors.New("table does not have rows")
+
+// NotFoundError is returned when there is no table with the name
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s table not found", e.Name)
+}
+
+// NoRowError is returned when a dice table has no row for the roll
+type NoRowError struct {
+	Roll int
+}
+
+func (e *NoRowError) Error() string {
+	return fmt.Sprintf("no row for a roll of %d", e.Roll)
+}
+
+// DiceError is returned when the dice of a table can't be parsed or rolled
+type DiceError struct {
+	Dice string
+	Err  error
+}
+
+func (e *DiceError) Error() string {
+	return fmt.Sprintf("can't roll %s: %v", e.Dice, e.Err)
+}
+
+func (e *DiceError) Unwrap() error {
+	return e.Err
+}
+
+// TableError is returned when an item can't be picked from a table
+type TableError struct {
+	Table string
+	Err   error
+}
+
+func (e *TableError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Table, e.Err)
+}
+
+func (e *TableError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,78 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+const errorsTest = `
+# Errors
+
+| 1d1 | Gap   |
+| --- | ----- |
+| 2   | never |
+
+| Pick         |
+| ------------ |
+| {{ pick }}   |
+
+| Empty | deck |
+| ----- | ---- |
+| coin  | 0    |
+
+| Repeat                     |
+| -------------------------- |
+| {{ norepeat "./one" -1 }}  |
+
+| One |
+| --- |
+| x   |
+
+` + "```" + ` note
+Just text
+` + "```" + `
+`
+
+func TestTableErrors(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(errorsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err := tree.GetItem(ctx, "errors/missing")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) || notFound.Name != "errors/missing" {
+		t.Errorf("Expected the missing table to be not found but got %v", err)
+	}
+
+	_, trace, err := tree.GetItemWithTrace(ctx, "errors/gap")
+	var tableErr *TableError
+	var noRow *NoRowError
+	if !errors.As(err, &tableErr) || tableErr.Table != "errors/gap" || !errors.As(err, &noRow) || noRow.Roll != 1 {
+		t.Errorf("Expected no row for the roll on the gap table but got %v", err)
+	}
+	if trace.Roll == nil || *trace.Roll != 1 {
+		t.Errorf("Expected the trace to hold the roll but got %+v", trace)
+	}
+
+	if _, err := tree.GetItem(ctx, "errors/pick"); !errors.Is(err, ErrNothingToPick) {
+		t.Errorf("Expected pick to need items but got %v", err)
+	}
+	if _, err := tree.GetItem(ctx, "errors/empty"); !errors.Is(err, ErrEmptyTable) {
+		t.Errorf("Expected the deck to be empty but got %v", err)
+	}
+	if _, _, err := tree.GetItemWithModifier(ctx, "errors/empty", RollModifier{Bonus: 1}); !errors.Is(err, ErrCannotModify) {
+		t.Errorf("Expected a deck to refuse a modifier but got %v", err)
+	}
+	if item, err := tree.GetItem(ctx, "errors/repeat"); err != nil || item != "x" {
+		t.Errorf("Expected norepeat to ignore a negative count but got %q %v", item, err)
+	}
+	if _, _, err := tree.GetRow(ctx, "errors/note"); !errors.Is(err, ErrNoRows) {
+		t.Errorf("Expected text to have no rows but got %v", err)
+	}
+}
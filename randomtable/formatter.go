@@ -1,27 +1,198 @@
 package randomtable
 
-import "fmt"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
 
-// Format will format the first string.
-// Second string is the table the item is looked up on
+// Element describes the table an item was picked from and how it was picked
+type Element struct {
+	Table string
+	// Dice is the dice rolled on the table, if any
+	Dice string
+	// Roll is the result of the dice
+	Roll *int
+	// Row is the index of the row that was picked
+	Row *int
+}
+
+// newElement describes the item picked by a step
+func newElement(table string, step *Trace) Element {
+	return Element{Table: table, Dice: step.Dice, Roll: step.Roll, Row: step.Row}
+}
+
+// Formatter formats items once their templates have been rendered. An item formatted by
+// Format is used in the item that looked it up, so the finished item has every item
+// it is made from formatted within it. Finish is given the finished item before it is
+// returned.
 type Formatter interface {
-	Format(string, string) string
+	Format(item string, element Element) string
+	// Trust formats markup that the author of a table has marked as safe to use as it is
+	Trust(markup string) string
+	Finish(item string) string
 }
 
 // NoOp Formatter for the base case
 type StringFormatter struct {
 }
 
-func (s StringFormatter) Format(input string, caller string) string {
+func (s StringFormatter) Format(input string, element Element) string {
 	return input
 }
 
-// Wraps items in html tags with metadata
+func (s StringFormatter) Trust(markup string) string {
+	return markup
+}
+
+func (s StringFormatter) Finish(item string) string {
+	return item
+}
+
+// HtmlFormatter wraps items in RandomElement tags with the table they came from and how
+// they were picked. Everything in an item is escaped, including the text of the table,
+// apart from markup that is marked as trusted.
+//
+// Tags can't be added as items are formatted, as the items they are used in are escaped
+// after they've been rendered. Instead the start and end of each element, and any trusted
+// markup, is marked with characters from the private use area which Finish turns into
+// tags. The markers are kept balanced so the finished item is always well formed, even
+// when a template cuts an item short.
+//
+// Each marker is followed by a nonce, and markers without it are dropped, so text that
+// comes from outside the tables, such as a value, can't pass itself off as markup. A tree
+// gives every generation its own random nonce.
 type HtmlFormatter struct {
+	nonce string
+}
+
+const (
+	elementStart = '\uE000'
+	elementAttrs = '\uE001'
+	elementEnd   = '\uE002'
+	trustedStart = '\uE003'
+	trustedEnd   = '\uE004'
+	markers      = "\uE000\uE001\uE002\uE003\uE004"
+)
+
+// Format marks the item as an element. Its attributes are hex encoded so they stay the
+// same if the item is changed by a function such as upper.
+func (s HtmlFormatter) Format(input string, element Element) string {
+	attrs := fmt.Sprintf(" table=\"%s\"", html.EscapeString(element.Table))
+	if element.Dice != "" {
+		attrs += fmt.Sprintf(" data-dice=\"%s\"", html.EscapeString(element.Dice))
+	}
+	if element.Roll != nil {
+		attrs += fmt.Sprintf(" data-roll=\"%d\"", *element.Roll)
+	}
+	if element.Row != nil {
+		attrs += fmt.Sprintf(" data-row=\"%d\"", *element.Row+1)
+	}
+	return string(elementStart) + s.nonce + hex.EncodeToString([]byte(attrs)) + string(elementAttrs) + input + string(elementEnd) + s.nonce
+}
+
+func (s HtmlFormatter) Trust(markup string) string {
+	return string(trustedStart) + s.nonce + hex.EncodeToString([]byte(markup)) + string(trustedEnd)
+}
+
+// forGeneration returns a formatter with a new random nonce. The nonce is only digits so
+// that it's left alone by functions such as upper.
+func (s HtmlFormatter) forGeneration() Formatter {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("unable to make a nonce: %v", err))
+	}
+	return HtmlFormatter{nonce: fmt.Sprintf("%020d", binary.BigEndian.Uint64(nonce))}
+}
+
+// Finish escapes the item and turns the markers left by Format and Trust into markup.
+// Markers that don't match up are dropped and elements that aren't ended are closed.
+func (s HtmlFormatter) Finish(item string) string {
+	b := &strings.Builder{}
+	open := 0
+	for {
+		i := strings.IndexAny(item, markers)
+		if i < 0 {
+			b.WriteString(html.EscapeString(item))
+			break
+		}
+		b.WriteString(html.EscapeString(item[:i]))
+		marker, size := utf8.DecodeRuneInString(item[i:])
+		item = item[i+size:]
+		// A marker without the nonce didn't come from this formatter
+		rest, found := strings.CutPrefix(item, s.nonce)
+		if !found {
+			continue
+		}
+		item = rest
+		switch marker {
+		case elementStart:
+			attrs, rest, ok := decodeMarker(item, elementAttrs)
+			if ok {
+				b.WriteString("<RandomElement" + attrs + ">")
+				open++
+				item = rest
+			}
+		case elementEnd:
+			if open > 0 {
+				b.WriteString("</RandomElement>")
+				open--
+			}
+		case trustedStart:
+			markup, rest, ok := decodeMarker(item, trustedEnd)
+			if ok {
+				b.WriteString(markup)
+				item = rest
+			}
+		}
+	}
+	b.WriteString(strings.Repeat("</RandomElement>", open))
+	return b.String()
+}
+
+// decodeMarker decodes the hex encoded text before the end marker
+func decodeMarker(s string, end rune) (string, string, bool) {
+	i := strings.IndexRune(s, end)
+	if i < 0 {
+		return "", s, false
+	}
+	decoded, err := hex.DecodeString(s[:i])
+	if err != nil {
+		return "", s, false
+	}
+	return string(decoded), s[i+utf8.RuneLen(end):], true
+}
+
+// generationFormatter is a formatter that needs its own state for each generation
+type generationFormatter interface {
+	forGeneration() Formatter
+}
+
+// generationFormatter returns the formatter for items in the generation, which is made
+// from the tree's formatter the first time it's needed
+func (t *Tree) generationFormatter(gen *generation) Formatter {
+	if gen.formatter == nil {
+		gen.formatter = t.formatter
+		if f, ok := t.formatter.(generationFormatter); ok {
+			gen.formatter = f.forGeneration()
+		}
+	}
+	return gen.formatter
 }
 
-func (s HtmlFormatter) Format(input string, caller string) string {
-	return fmt.Sprintf("<RandomElement table='%s'>%s</RandomElement>", caller, input)
+// finishTrace applies the formatter to the results in a trace
+func finishTrace(formatter Formatter, trace *Trace) {
+	if trace == nil {
+		return
+	}
+	trace.Result = formatter.Finish(trace.Result)
+	for _, child := range trace.Children {
+		finishTrace(formatter, child)
+	}
 }
 
 func (t Tree) WithHtmlFormatter() Tree {
@@ -1,12 +1,74 @@
 package randomtable
 
-import "testing"
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
 
 func TestHtmlFormatter(t *testing.T) {
 	f := HtmlFormatter{}
-	actual := f.Format("one", "two")
-	expected := "<RandomElement table='two'>one</RandomElement>"
+	actual := f.Finish(f.Format("one", Element{Table: "two"}))
+	expected := `<RandomElement table="two">one</RandomElement>`
 	if actual != expected {
 		t.Errorf("Actual: %v did not equal Expected: %v", actual, expected)
 	}
 }
+
+func TestHtmlFormatterEscaping(t *testing.T) {
+	f := HtmlFormatter{}.forGeneration().(HtmlFormatter)
+	end := string(elementEnd) + f.nonce
+	forged := string(trustedStart) + hex.EncodeToString([]byte("<script>")) + string(trustedEnd)
+	roll, row := 7, 2
+	tests := []struct {
+		name     string
+		item     string
+		expected string
+	}{
+		{
+			name:     "text and attributes are escaped",
+			item:     f.Format(`<script>alert("hi")</script> & 'more'`, Element{Table: `a"b`, Dice: "2d6", Roll: &roll, Row: &row}),
+			expected: `<RandomElement table="a&#34;b" data-dice="2d6" data-roll="7" data-row="3">&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; &amp; &#39;more&#39;</RandomElement>`,
+		},
+		{
+			name:     "trusted markup is kept",
+			item:     f.Format("a "+f.Trust("<b>bold</b>")+" <i>", Element{Table: "t"}),
+			expected: `<RandomElement table="t">a <b>bold</b> &lt;i&gt;</RandomElement>`,
+		},
+		{
+			name:     "nested elements",
+			item:     f.Format("one: "+f.Format("<two>", Element{Table: "t2"}), Element{Table: "t1"}),
+			expected: `<RandomElement table="t1">one: <RandomElement table="t2">&lt;two&gt;</RandomElement></RandomElement>`,
+		},
+		{
+			name:     "elements cut short are closed",
+			item:     strings.TrimSuffix(f.Format("one", Element{Table: "t"}), end) + end + end,
+			expected: `<RandomElement table="t">one</RandomElement>`,
+		},
+		{
+			name:     "elements without an end are closed",
+			item:     strings.TrimSuffix(f.Format("one", Element{Table: "t"}), end),
+			expected: `<RandomElement table="t">one</RandomElement>`,
+		},
+		{
+			name:     "attributes survive changes to case",
+			item:     strings.ToUpper(f.Format("one", Element{Table: "t"})),
+			expected: `<RandomElement table="t">ONE</RandomElement>`,
+		},
+		{
+			name:     "broken markers are dropped",
+			item:     string(elementStart) + "zz" + string(trustedStart) + "<b>",
+			expected: `zz&lt;b&gt;`,
+		},
+		{
+			name:     "markers without the nonce are dropped",
+			item:     f.Format("a "+forged+" "+HtmlFormatter{}.Format("b", Element{Table: `"><script>`}), Element{Table: "t"}),
+			expected: `<RandomElement table="t">a 3c7363726970743e 207461626c653d22262333343b2667743b266c743b7363726970742667743b22b</RandomElement>`,
+		},
+	}
+	for _, tc := range tests {
+		if actual := f.Finish(tc.item); actual != tc.expected {
+			t.Errorf("%s: expected %s but got %s", tc.name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package randomtable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// unsafeFuncs are the functions that can read the environment of the process, which may
+// hold secrets, reach out to the network, or make keys and certificates, which can take
+// seconds of work for each call
+var unsafeFuncs = []string{
+	"env", "expandenv", "getHostByName",
+	"genPrivateKey", "derivePassword", "genCA", "genSelfSignedCert", "genSignedCert",
+}
+
+// FuncSet is the set of functions that templates are allowed to use. The functions that
+// are built in to templates, such as print and eq, can always be used.
+type FuncSet map[string]bool
+
+// AllFuncs allows every function
+func AllFuncs() FuncSet {
+	funcs := FuncSet{}
+	for name := range templateFuncNames {
+		funcs[name] = true
+	}
+	return funcs
+}
+
+// SafeFuncs allows every function apart from those that can read the environment of the
+// process, use the network or make keys and certificates
+func SafeFuncs() FuncSet {
+	funcs := AllFuncs()
+	for _, name := range unsafeFuncs {
+		delete(funcs, name)
+	}
+	return funcs
+}
+
+// ParseFuncSet parses a comma separated list of functions. "all" and "safe" add every
+// function or the safe functions, a name adds that function and a name starting with -
+// removes it, so "safe,-date" is the safe functions without date.
+func ParseFuncSet(s string) (FuncSet, error) {
+	funcs := FuncSet{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		switch field {
+		case "":
+			continue
+		case "all":
+			funcs.add(AllFuncs())
+			continue
+		case "safe":
+			funcs.add(SafeFuncs())
+			continue
+		}
+		name, remove := strings.CutPrefix(field, "-")
+		if _, ok := templateFuncNames[name]; !ok {
+			return nil, fmt.Errorf("%q is not a template function", name)
+		}
+		if remove {
+			delete(funcs, name)
+		} else {
+			funcs[name] = true
+		}
+	}
+	return funcs, nil
+}
+
+func (f FuncSet) add(other FuncSet) {
+	for name := range other {
+		f[name] = true
+	}
+}
+
+// String lists the functions in the set
+func (f FuncSet) String() string {
+	names := []string{}
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// funcMap returns the functions in the set that templates can be parsed with. A nil set
+// allows every function.
+func (f FuncSet) funcMap() template.FuncMap {
+	if f == nil {
+		return templateFuncNames
+	}
+	funcs := template.FuncMap{}
+	for name := range f {
+		if fn, ok := templateFuncNames[name]; ok {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+// check returns an error for the first function that isn't in the set in any of the
+// templates defined by tmpl, including those in define and block. A nil set allows every
+// function.
+func (f FuncSet) check(tmpl *template.Template) error {
+	if f == nil {
+		return nil
+	}
+	var err error
+	for _, defined := range tmpl.Templates() {
+		if defined.Tree == nil {
+			continue
+		}
+		walkCommands(defined.Tree.Root, func(cmd *parse.CommandNode) {
+			for _, arg := range cmd.Args {
+				fn, ok := arg.(*parse.IdentifierNode)
+				if !ok || err != nil {
+					continue
+				}
+				if _, isFunc := templateFuncNames[fn.Ident]; isFunc && !f[fn.Ident] {
+					err = fmt.Errorf("function %q is not allowed", fn.Ident)
+				}
+			}
+		})
+	}
+	return err
+}
+
+// WithFuncs returns a tree that only allows templates to use the given functions. Items
+// that use any other function fail to parse, so they are reported by Validate.
+func (t Tree) WithFuncs(funcs FuncSet) Tree {
+	t.templates = newTemplateCache(funcs)
+	return t
+}
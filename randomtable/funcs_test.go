@@ -0,0 +1,134 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseFuncSet(t *testing.T) {
+	tests := []struct {
+		spec    string
+		allowed []string
+		blocked []string
+	}{
+		{spec: "all", allowed: []string{"env", "lookup", "upper"}},
+		{spec: "safe", allowed: []string{"lookup", "upper", "trusted"}, blocked: []string{"env", "expandenv", "getHostByName", "genPrivateKey", "derivePassword", "genCA", "genSelfSignedCert", "genSignedCert"}},
+		{spec: "safe, -upper, env", allowed: []string{"lookup", "env"}, blocked: []string{"upper", "expandenv"}},
+		{spec: "lookup,roll", allowed: []string{"lookup", "roll"}, blocked: []string{"upper", "pick"}},
+	}
+	for _, tc := range tests {
+		funcs, err := ParseFuncSet(tc.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.spec, err)
+		}
+		for _, name := range tc.allowed {
+			if !funcs[name] {
+				t.Errorf("%s: expected %s to be allowed", tc.spec, name)
+			}
+		}
+		for _, name := range tc.blocked {
+			if funcs[name] {
+				t.Errorf("%s: expected %s to be blocked", tc.spec, name)
+			}
+		}
+	}
+	if _, err := ParseFuncSet("safe,nothing"); err == nil {
+		t.Error("Expected an error for a function that doesn't exist")
+	}
+}
+
+const funcsTest = `
+# Funcs
+
+| Secret                                       |
+| -------------------------------------------- |
+| {{ if true }}{{ print (env "HOME") }}{{ end }} |
+
+| Fine                                 |
+| ------------------------------------ |
+| {{ upper "a" }} {{ printf "%d" 1 }}  |
+`
+
+func TestWithFuncs(t *testing.T) {
+	tree := NewTree().WithFuncs(SafeFuncs())
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(funcsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	issues := tree.Validate()
+	if len(issues) != 1 || issues[0].Table != "funcs/secret" || issues[0].Kind != IssueParseError {
+		t.Fatalf("Expected a parse error for the secret table but got %v", issues)
+	}
+	if !strings.Contains(issues[0].Message, `function "env" is not allowed`) {
+		t.Errorf("Expected the error to name the function but got %s", issues[0].Message)
+	}
+	if _, err := tree.GetItem(context.Background(), "funcs/secret"); err == nil {
+		t.Error("Expected an error rendering a blocked function")
+	}
+	item, err := tree.GetItem(context.Background(), "funcs/fine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "A 1" {
+		t.Errorf("Expected A 1 but got %s", item)
+	}
+	// Every function is allowed again with all of them
+	all := tree.WithFuncs(AllFuncs())
+	if issues := all.Validate(); len(issues) != 0 {
+		t.Errorf("Expected no issues but got %v", issues)
+	}
+}
+
+func TestWithFuncsDefinedTemplates(t *testing.T) {
+	t.Setenv("MAKEMEA_SECRET", "hunter2")
+	cases := []struct {
+		name string
+		item string
+		fn   string
+	}{
+		{name: "define", item: `{{define "x"}}{{env "MAKEMEA_SECRET"}}{{end}}{{template "x"}}`, fn: "env"},
+		{name: "block", item: `{{block "y" .}}{{expandenv "$MAKEMEA_SECRET"}}{{end}}`, fn: "expandenv"},
+	}
+	for _, tc := range cases {
+		tree := NewTree().WithFuncs(SafeFuncs())
+		table := NewRandomTable()
+		table.AddItem(tc.item)
+		if _, err := tree.AddTable("secret", &table, false); err != nil {
+			t.Fatal(err)
+		}
+		issues := tree.Validate()
+		if len(issues) != 1 || issues[0].Kind != IssueParseError {
+			t.Fatalf("%s: Expected a parse error but got %v", tc.name, issues)
+		}
+		if !strings.Contains(issues[0].Message, fmt.Sprintf("function %q is not allowed", tc.fn)) {
+			t.Errorf("%s: Expected the error to name %s but got %s", tc.name, tc.fn, issues[0].Message)
+		}
+		item, err := tree.GetItem(context.Background(), "secret")
+		if err == nil || strings.Contains(item, "hunter2") {
+			t.Errorf("%s: Expected an error rendering a blocked function but got %q, %v", tc.name, item, err)
+		}
+	}
+}
+
+func TestBindAllowedFuncs(t *testing.T) {
+	cached := parseTemplate("{{ upper \"a\" }}", FuncSet{"upper": true, "roll": true})
+	if cached.err != nil {
+		t.Fatal(cached.err)
+	}
+	bound, err := cached.bind(context.Background(), template.FuncMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cached.release(bound)
+	if _, err := bound.tmpl.New("lookup").Parse(`{{ lookup "x" }}`); err == nil {
+		t.Error("Expected lookup not to be bound when it isn't allowed")
+	}
+	if _, err := bound.tmpl.New("roll").Parse(`{{ roll "1d6" }}`); err != nil {
+		t.Errorf("Expected roll to be bound but got %s", err)
+	}
+}
@@ -0,0 +1,127 @@
+package randomtable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a problem found while validating tables is
+type Severity int
+
+const (
+	// SeverityInfo is for things that are probably intended but worth knowing about
+	SeverityInfo Severity = iota
+	// SeverityWarning is for tables that work but not as they were probably meant to
+	SeverityWarning
+	// SeverityError is for tables that fail when they are used
+	SeverityError
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity with the given name
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, use one of %s", name, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// IssueKind names the kind of problem an issue describes
+type IssueKind string
+
+const (
+	IssueInvalidDice    IssueKind = "invalid-dice"
+	IssueUnchecked      IssueKind = "unchecked"
+	IssueUnreachable    IssueKind = "unreachable"
+	IssueGap            IssueKind = "gap"
+	IssueDuplicateRow   IssueKind = "duplicate-row"
+	IssueInvalidWeight  IssueKind = "invalid-weight"
+	IssueEmptyTable     IssueKind = "empty-table"
+	IssueDuplicateTable IssueKind = "duplicate-table"
+	IssueParseError     IssueKind = "parse-error"
+	IssueRenderError    IssueKind = "render-error"
+	IssueMissingTable   IssueKind = "missing-table"
+	IssueCycle          IssueKind = "cycle"
+)
+
+// Issue is a single problem found while validating tables. Tables only fill in the
+// severity, kind and message. The tree adds where the table came from.
+type Issue struct {
+	Severity Severity  `json:"severity"`
+	Kind     IssueKind `json:"kind"`
+	Table    string    `json:"table,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Line     int       `json:"line,omitempty"`
+	Message  string    `json:"message"`
+}
+
+func newIssue(severity Severity, kind IssueKind, format string, args ...interface{}) Issue {
+	return Issue{Severity: severity, Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// String formats the issue as file:line: severity: table: message [kind]
+func (i Issue) String() string {
+	b := &strings.Builder{}
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(b, ":%d", i.Line)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Severity.String() + ": ")
+	if i.Table != "" {
+		b.WriteString(i.Table + ": ")
+	}
+	fmt.Fprintf(b, "%s [%s]", i.Message, i.Kind)
+	return b.String()
+}
+
+// sortIssues orders issues by where they were found
+func sortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(x, y int) bool {
+		a, b := issues[x], issues[y]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Table < b.Table
+	})
+}
+
+// MaxSeverity returns the most serious severity of the issues, or -1 when there are none
+func MaxSeverity(issues []Issue) Severity {
+	max := Severity(-1)
+	for _, issue := range issues {
+		if issue.Severity > max {
+			max = issue.Severity
+		}
+	}
+	return max
+}
@@ -0,0 +1,77 @@
+package randomtable
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const validateTest = `
+| 1d6 | Monster |
+| --- | ------- |
+| 1-3 | Orc     |
+| 3   | Goblin  |
+| 4-5 | Troll   |
+| 7   | Dragon  |
+
+| Loot | weight |
+| ---- | ------ |
+| Gold | x      |
+
+| Bad               |
+| ----------------- |
+| {{lookup "nope"}} |
+| {{if}}            |
+
+| Empty |
+| ----- |
+`
+
+func TestValidate(t *testing.T) {
+	tree := NewTree()
+	for i, source := range []string{validateTest, "| Loot |\n| --- |\n| Gems |\n"} {
+		md := NewMarkdownParser(tree.WithFile([]string{"first.md", "second.md"}[i]))
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(source), &buf); err != nil {
+			t.Error(err)
+		}
+	}
+	type found struct {
+		Severity Severity
+		Kind     IssueKind
+		Table    string
+		File     string
+		Line     int
+	}
+	results := []found{}
+	for _, issue := range tree.Validate() {
+		results = append(results, found{issue.Severity, issue.Kind, issue.Table, issue.File, issue.Line})
+	}
+	expected := []found{
+		{SeverityWarning, IssueDuplicateRow, "monster", "first.md", 2},
+		{SeverityWarning, IssueUnreachable, "monster", "first.md", 2},
+		{SeverityWarning, IssueGap, "monster", "first.md", 2},
+		{SeverityError, IssueParseError, "bad", "first.md", 13},
+		{SeverityError, IssueMissingTable, "bad", "first.md", 13},
+		{SeverityWarning, IssueEmptyTable, "empty", "first.md", 18},
+		{SeverityWarning, IssueDuplicateTable, "loot", "second.md", 1},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected issues\n%v\nbut got\n%v", expected, results)
+	}
+	if max := MaxSeverity(tree.Validate()); max != SeverityError {
+		t.Errorf("Expected the worst issue to be an error but got %v", max)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		parsed, err := ParseSeverity(s.String())
+		if err != nil || parsed != s {
+			t.Errorf("Expected %v but got %v, %v", s, parsed, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("Expected an unknown severity to fail")
+	}
+}
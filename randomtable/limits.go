@@ -0,0 +1,176 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/sprig"
+)
+
+// sprigFuncs are sprig's own functions, including those the tree replaces with ones that
+// keep to the limits
+var sprigFuncs = sprig.GenericFuncMap()
+
+var (
+	sprigRepeat  = sprigFuncs["repeat"].(func(int, string) string)
+	sprigIndent  = sprigFuncs["indent"].(func(int, string) string)
+	sprigNindent = sprigFuncs["nindent"].(func(int, string) string)
+)
+
+// Limits bound the work that can be done to generate a single item, so that a table can't
+// keep a machine busy forever. A limit of 0 is no limit. The time an item can take is
+// bounded by the deadline of the context it's generated with.
+type Limits struct {
+	// MaxLookups is how many tables can be looked up, drawn from or fudged
+	MaxLookups int
+	// MaxOutput is the length in bytes of any item, including the items it's made from
+	MaxOutput int
+	// MaxDice is how many dice can be rolled, by tables and by roll and fudge
+	MaxDice int
+	// MaxRange is how many numbers until and untilStep can make in total for templates to
+	// range over
+	MaxRange int
+}
+
+// DefaultLimits are far more than any sensible table needs
+var DefaultLimits = Limits{MaxLookups: 10000, MaxOutput: 1 << 20, MaxDice: 10000, MaxRange: 10000}
+
+type limitsKey struct{}
+
+// WithLimits returns a context that generates items within the given limits
+func WithLimits(ctx context.Context, limits Limits) context.Context {
+	return context.WithValue(ctx, limitsKey{}, limits)
+}
+
+// LimitsFromContext returns the limits for items generated with the context. A context
+// without limits has no limits.
+func LimitsFromContext(ctx context.Context) Limits {
+	limits, _ := ctx.Value(limitsKey{}).(Limits)
+	return limits
+}
+
+// LimitError is returned when generating an item goes over one of its limits
+type LimitError struct {
+	// Limit is what was limited, such as lookups
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("generating the item went over the limit of %d %s", e.Max, e.Limit)
+}
+
+// limitedWriter holds the output of a template. Writes fail once the output is too long
+// or the generation has been cancelled, which stops the template.
+type limitedWriter struct {
+	buf bytes.Buffer
+	gen *generation
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if err := w.gen.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if max := w.gen.limits.MaxOutput; max > 0 && w.buf.Len()+len(p) > max {
+		return 0, &LimitError{Limit: "bytes of output", Max: max}
+	}
+	return w.buf.Write(p)
+}
+
+func (w *limitedWriter) String() string {
+	return w.buf.String()
+}
+
+// run calls generate in its own goroutine and returns once it's done or the generation's
+// context is. Templates check the context before every function they call, so generate
+// stops soon after, but anything it sets must only be used when run returns without the
+// context being done. A panic while generating is returned as an error rather than
+// taking down the process.
+func (g *generation) run(generate func() error) error {
+	generate = recoverGenerate(generate)
+	if g.ctx.Done() == nil {
+		return generate()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- generate()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	}
+}
+
+// recoverGenerate returns generate with any panic it makes returned as an error
+func recoverGenerate(generate func() error) func() error {
+	return func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("generating the item failed: %v", r)
+			}
+		}()
+		return generate()
+	}
+}
+
+// untilStep counts from start towards stop, like sprig's untilStep, but returns an error
+// rather than making more numbers than the generation allows. The numbers from every range
+// count towards the limit, so nested ranges can't multiply it.
+func (g *generation) untilStep(start, stop, step int) ([]int, error) {
+	if err := g.ctx.Err(); err != nil {
+		return nil, err
+	}
+	count := 0
+	switch {
+	case step > 0 && start < stop:
+		count = (stop - start + step - 1) / step
+	case step < 0 && start > stop:
+		count = (start - stop - step - 1) / -step
+	}
+	if g.limits.MaxRange > 0 && count > g.limits.MaxRange-g.ranged {
+		return nil, &LimitError{Limit: "numbers in ranges", Max: g.limits.MaxRange}
+	}
+	g.ranged += count
+	numbers := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		numbers = append(numbers, start+i*step)
+	}
+	return numbers, nil
+}
+
+// checkSize returns an error when count pieces of size bytes would go over the
+// generation's limit on output
+func (g *generation) checkSize(count, size int) error {
+	if max := g.limits.MaxOutput; max > 0 && size > 0 && count > max/size {
+		return &LimitError{Limit: "bytes of output", Max: max}
+	}
+	return nil
+}
+
+// repeat is sprig's repeat within the generation's limit on output
+func (g *generation) repeat(count int, str string) (string, error) {
+	if err := g.checkSize(count, len(str)); err != nil {
+		return "", err
+	}
+	return sprigRepeat(count, str), nil
+}
+
+// indent is sprig's indent within the generation's limit on output
+func (g *generation) indent(spaces int, v string) (string, error) {
+	if err := g.checkSize(spaces, strings.Count(v, "\n")+1); err != nil {
+		return "", err
+	}
+	return sprigIndent(spaces, v), nil
+}
+
+// nindent is sprig's nindent within the generation's limit on output
+func (g *generation) nindent(spaces int, v string) (string, error) {
+	if err := g.checkSize(spaces, strings.Count(v, "\n")+1); err != nil {
+		return "", err
+	}
+	return sprigNindent(spaces, v), nil
+}
@@ -0,0 +1,181 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const limitsTest = `
+# Limits
+
+| Many                     |
+| ------------------------ |
+| {{ lookup "./one" 100 }} |
+
+| One |
+| --- |
+| x   |
+
+| Long                                          |
+| --------------------------------------------- |
+| {{ range until 1000 }}xxxxxxxxxx{{ end }}     |
+
+| Dice              |
+| ----------------- |
+| {{ roll "50d6" }} |
+
+| 30d6    | Table |
+| ------- | ----- |
+| 30-100  | low   |
+| 101-180 | high  |
+
+| Nested                                                   |
+| -------------------------------------------------------- |
+| {{ range until 100 }}{{ range until 100 }}{{ end }}{{ end }} |
+
+| Repeat                                       |
+| -------------------------------------------- |
+| {{ $x := repeat 10000 "x" }}{{ len $x }}     |
+
+| Random                            |
+| --------------------------------- |
+| {{ len (randAlpha 10000) }}       |
+
+| Busy                                                                                   |
+| -------------------------------------------------------------------------------------- |
+| {{ range splitList "," (repeat 100000 ",") }}{{ $x := splitList "," (repeat 100 ",") }}{{ end }} |
+
+| Forever                                              |
+| ---------------------------------------------------- |
+| {{ range until 1000000 }}{{ lookup "./one" }}{{ end }} |
+`
+
+func TestLimits(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(limitsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		table  string
+		limits Limits
+		limit  string
+	}{
+		{table: "limits/many", limits: Limits{MaxLookups: 100}, limit: "lookups"},
+		{table: "limits/long", limits: Limits{MaxOutput: 5000}, limit: "bytes of output"},
+		{table: "limits/dice", limits: Limits{MaxDice: 40}, limit: "dice"},
+		{table: "limits/table", limits: Limits{MaxDice: 20}, limit: "dice"},
+		{table: "limits/nested", limits: Limits{MaxRange: 10000}, limit: "numbers in ranges"},
+		{table: "limits/repeat", limits: Limits{MaxOutput: 5000}, limit: "bytes of output"},
+		{table: "limits/random", limits: Limits{MaxOutput: 5000}, limit: "bytes of output"},
+	}
+	for _, tc := range tests {
+		ctx := WithLimits(context.Background(), tc.limits)
+		_, err := tree.GetItem(ctx, tc.table)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tc.limit {
+			t.Errorf("%s: expected to go over the limit of %s but got %v", tc.table, tc.limit, err)
+		}
+		// Doubling the limit leaves room for the item
+		if tc.limits.MaxLookups > 0 {
+			tc.limits.MaxLookups *= 2
+		}
+		tc.limits.MaxOutput *= 2
+		tc.limits.MaxDice *= 2
+		tc.limits.MaxRange *= 2
+		if _, err := tree.GetItem(WithLimits(context.Background(), tc.limits), tc.table); err != nil {
+			t.Errorf("%s: expected the item within %+v but got %v", tc.table, tc.limits, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := tree.GetItem(ctx, "limits/forever"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop the item but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the item to stop soon after the deadline but it took %s", elapsed)
+	}
+	// A template that's busy without checking the context still stops at the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := tree.GetItem(ctx, "limits/busy"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop the busy item but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the busy item to stop at the deadline but it took %s", elapsed)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := tree.GetRow(cancelled, "limits/one"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the row but got %v", err)
+	}
+}
+
+func TestUntilStep(t *testing.T) {
+	ctx := WithLimits(context.Background(), Limits{MaxRange: 5})
+	tests := []struct {
+		start, stop, step int
+		expected          []int
+	}{
+		{0, 5, 1, []int{0, 1, 2, 3, 4}},
+		{0, 5, 2, []int{0, 2, 4}},
+		{5, 0, -2, []int{5, 3, 1}},
+		{0, -3, -1, []int{0, -1, -2}},
+		{0, 5, -1, []int{}},
+		{5, 0, 1, []int{}},
+		{0, 5, 0, []int{}},
+	}
+	for _, tc := range tests {
+		actual, err := newGeneration(ctx).untilStep(tc.start, tc.stop, tc.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("untilStep %d %d %d: expected %v but got %v", tc.start, tc.stop, tc.step, tc.expected, actual)
+		}
+	}
+	if _, err := newGeneration(ctx).untilStep(0, 6, 1); err == nil {
+		t.Error("Expected an error for a range over the limit")
+	}
+	// Every range counts towards the same limit
+	gen := newGeneration(ctx)
+	if _, err := gen.untilStep(0, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.untilStep(0, 3, 1); err == nil {
+		t.Error("Expected an error once the ranges together go over the limit")
+	}
+}
+
+func TestRunPanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, ctx := range []context.Context{context.Background(), ctx} {
+		err := newGeneration(ctx).run(func() error {
+			panic("boom")
+		})
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Expected the panic as an error but got %v", err)
+		}
+	}
+}
+
+func TestFuncsCheckContext(t *testing.T) {
+	tree := NewTree()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Nothing is written or looked up so only the call to upper can notice the context
+	_, err := tree.renderItem(newGeneration(cancelled), `{{ $x := upper "a" }}`, "", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the function but got %v", err)
+	}
+}
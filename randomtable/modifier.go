@@ -0,0 +1,121 @@
+package randomtable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RollModifier changes how an item is picked from a table. The bonus is added to the roll of
+// a rolling table, or to the row picked from any other table, and a result past the first or
+// last row is given that row. Advantage picks twice and keeps the higher roll or row, and
+// disadvantage keeps the lower. With both they cancel out.
+type RollModifier struct {
+	Bonus        int
+	Advantage    bool
+	Disadvantage bool
+}
+
+// ParseRollModifier parses modifiers such as "+2", "-1", "advantage" or "+2 dis"
+func ParseRollModifier(s string) (RollModifier, error) {
+	mod := RollModifier{}
+	for _, field := range strings.Fields(s) {
+		if err := mod.add(field); err != nil {
+			return mod, err
+		}
+	}
+	return mod, nil
+}
+
+// add applies a single modifier to m
+func (m *RollModifier) add(field string) error {
+	switch strings.ToLower(field) {
+	case "adv", "advantage":
+		m.Advantage = true
+		return nil
+	case "dis", "disadvantage":
+		m.Disadvantage = true
+		return nil
+	}
+	if strings.HasPrefix(field, "+") || strings.HasPrefix(field, "-") {
+		bonus, err := strconv.Atoi(field)
+		if err == nil {
+			m.Bonus += bonus
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a roll modifier, use a bonus such as +2 or -1, advantage or disadvantage", field)
+}
+
+// IsZero is true when the modifier doesn't change any rolls
+func (m RollModifier) IsZero() bool {
+	return m.Bonus == 0 && m.Advantage == m.Disadvantage
+}
+
+// String formats the modifier so that it can be parsed again
+func (m RollModifier) String() string {
+	fields := []string{}
+	if m.Bonus != 0 {
+		fields = append(fields, fmt.Sprintf("%+d", m.Bonus))
+	}
+	if m.Advantage && !m.Disadvantage {
+		fields = append(fields, "advantage")
+	}
+	if m.Disadvantage && !m.Advantage {
+		fields = append(fields, "disadvantage")
+	}
+	return strings.Join(fields, " ")
+}
+
+// apply makes a roll, or two with advantage or disadvantage, and returns the one that is
+// kept with the bonus added
+func (m RollModifier) apply(roll func() int) int {
+	result := roll()
+	if m.Advantage != m.Disadvantage {
+		second := roll()
+		if m.Advantage {
+			result = max(result, second)
+		} else {
+			result = min(result, second)
+		}
+	}
+	return result + m.Bonus
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// parseLookupArgs reads the optional arguments to lookup. A number is how many times to roll
+// and a roll modifier changes the roll. Any other string names an argument for the table and
+// the argument after it is its value.
+func parseLookupArgs(args []interface{}) (int, RollModifier, map[string]interface{}, error) {
+	times := 1
+	mod := RollModifier{}
+	named := map[string]interface{}{}
+	for i := 0; i < len(args); i++ {
+		switch a := args[i].(type) {
+		case int:
+			times = a
+		case string:
+			if n, err := strconv.Atoi(a); err == nil && !strings.HasPrefix(a, "+") && !strings.HasPrefix(a, "-") {
+				times = n
+				continue
+			}
+			if parsed, err := ParseRollModifier(a); err == nil {
+				mod.Bonus += parsed.Bonus
+				mod.Advantage = mod.Advantage || parsed.Advantage
+				mod.Disadvantage = mod.Disadvantage || parsed.Disadvantage
+				continue
+			}
+			if i+1 == len(args) {
+				return times, mod, named, fmt.Errorf("%q is not a number of rolls or a roll modifier and has no value to pass to the table", a)
+			}
+			named[a] = args[i+1]
+			i++
+		default:
+			return times, mod, named, fmt.Errorf("%v is not a number of rolls, a roll modifier or the name of an argument", a)
+		}
+	}
+	return times, mod, named, nil
+}
@@ -0,0 +1,137 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseRollModifier(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected RollModifier
+	}{
+		{input: "+2", expected: RollModifier{Bonus: 2}},
+		{input: "-1", expected: RollModifier{Bonus: -1}},
+		{input: "adv", expected: RollModifier{Advantage: true}},
+		{input: "+2 Disadvantage", expected: RollModifier{Bonus: 2, Disadvantage: true}},
+		{input: "+1 +2", expected: RollModifier{Bonus: 3}},
+	}
+	for _, tc := range cases {
+		mod, err := ParseRollModifier(tc.input)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tc.input, err)
+		}
+		if mod != tc.expected {
+			t.Errorf("Expected %+v for %q but got %+v", tc.expected, tc.input, mod)
+		}
+		if again, _ := ParseRollModifier(mod.String()); again != mod {
+			t.Errorf("Expected %q to parse back to %+v", mod.String(), mod)
+		}
+	}
+	for _, input := range []string{"2", "lucky", "+x"} {
+		if _, err := ParseRollModifier(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+	if !(RollModifier{Advantage: true, Disadvantage: true}).IsZero() {
+		t.Error("Expected advantage and disadvantage to cancel out")
+	}
+}
+
+const modifierTest = `
+| 1d6 | Encounter |
+| --- | --------- |
+| 1-2 | Rats      |
+| 3-4 | Wolves    |
+| 5-6 | Dragon    |
+
+| Danger                        |
+| ----------------------------- |
+| {{lookup "encounter" "+10"}}  |
+
+| Safe                          |
+| ----------------------------- |
+| {{lookup "encounter" 2 "-10"}} |
+
+| Name |
+| ---- |
+| Ann  |
+| Bob  |
+| Cid  |
+
+| Loot   | weight |
+| ------ | ------ |
+| Copper | 10     |
+| Never  | 0      |
+| Gold   | 1      |
+
+| Card | deck |
+| ---- | ---- |
+| Ace  |      |
+
+| Bad                              |
+| -------------------------------- |
+| {{lookup "encounter" "lucky"}}   |
+`
+
+func TestRollModifiers(t *testing.T) {
+	tree := NewTree().WithSeed(5)
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(modifierTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(table string, mod RollModifier, expected string) {
+		t.Helper()
+		for x := 0; x < 20; x++ {
+			item, _, err := tree.GetItemWithModifier(context.Background(), table, mod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item != expected {
+				t.Fatalf("Expected %s from %s with %+v but got %s", expected, table, mod, item)
+			}
+		}
+	}
+	// Rolls past the table get the first or last row
+	expect("danger", RollModifier{}, "Dragon")
+	expect("safe", RollModifier{}, "Rats, Rats")
+	expect("encounter", RollModifier{Bonus: -6}, "Rats")
+	expect("name", RollModifier{Bonus: 5}, "Cid")
+	// Rows that can't be picked are skipped
+	expect("loot", RollModifier{Bonus: 1}, "Gold")
+
+	// Advantage keeps the higher roll, so is never lower than a single roll on average
+	total := func(mod RollModifier) int {
+		sum := 0
+		tree := tree.WithSeed(9)
+		for x := 0; x < 500; x++ {
+			_, trace, err := tree.GetItemWithModifier(context.Background(), "encounter", mod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum += *trace.Roll
+		}
+		return sum
+	}
+	normal, adv, dis := total(RollModifier{}), total(RollModifier{Advantage: true}), total(RollModifier{Disadvantage: true})
+	if !(dis < normal && normal < adv) {
+		t.Errorf("Expected disadvantage %d < normal %d < advantage %d", dis, normal, adv)
+	}
+
+	_, trace, err := tree.GetItemWithModifier(context.Background(), "encounter", RollModifier{Bonus: 2, Advantage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Modifier != "+2 advantage" || !strings.Contains(trace.String(), "modifier: +2 advantage") {
+		t.Errorf("Expected the trace to show the modifier but got %s", trace)
+	}
+	if _, _, err := tree.GetItemWithModifier(context.Background(), "card", RollModifier{Bonus: 1}); err == nil {
+		t.Error("Expected an error modifying a draw from a deck")
+	}
+	if _, err := tree.GetItem(context.Background(), "bad"); err == nil || !strings.Contains(err.Error(), "lucky") {
+		t.Errorf("Expected an error for an unknown modifier but got %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package randomtable
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// DEFAULT_SAMPLES is the number of times an item is rendered when its odds can't be worked out exactly
+const DEFAULT_SAMPLES = 10000
+
+// maxOddsResults limits how many different results are tracked when combining the odds of lookups
+const maxOddsResults = 10000
+
+// errNotExact is returned when the odds of an item can't be worked out without sampling
+var errNotExact = errors.New("odds can't be worked out exactly")
+
+// noRowResult stands for the rolls on a dice table that have no row, which fail to give an
+// item. It can't be the text of a real result.
+const noRowResult = "\x00no row"
+
+// Odds is the chance of a single result from a table
+type Odds struct {
+	Result string  `json:"result"`
+	Chance float64 `json:"chance"`
+	// NoRow is the chance of rolling on a dice table where there isn't a row, which gives
+	// an error rather than an item. The result is empty.
+	NoRow bool `json:"noRow,omitempty"`
+}
+
+// Distribution is the chance of every result from a table, most likely first.
+// When Exact is false, some of the chances were estimated by rendering items Samples times.
+type Distribution struct {
+	Odds    []Odds `json:"odds"`
+	Exact   bool   `json:"exact"`
+	Samples int    `json:"samples,omitempty"`
+}
+
+// Distribution returns the chance of each result from the named table. Templates that
+// only use lookup and fudge are followed through to the tables they use. Any other
+// template is rendered many times to estimate its odds.
+func (t *Tree) Distribution(name string) (Distribution, error) {
+	return t.DistributionWithSamples(name, DEFAULT_SAMPLES)
+}
+
+// DistributionWithSamples is Distribution with the number of samples to use for templates that can't be followed
+func (t *Tree) DistributionWithSamples(name string, samples int) (Distribution, error) {
+	// Sampling shouldn't change the state of decks or wrap results in html. A fixed
+	// seed gives the same estimates every time.
+	sampler := t.WithSeed(0).WithStringFormatter()
+	s := &oddsState{
+		tree:     &sampler,
+		ctx:      WithLimits(context.Background(), DefaultLimits),
+		samples:  samples,
+		memo:     map[string]map[string]float64{},
+		visiting: map[string]bool{},
+		exact:    true,
+	}
+	chances, err := s.table(name)
+	if err != nil {
+		return Distribution{}, err
+	}
+	dist := Distribution{Exact: s.exact}
+	if !s.exact {
+		dist.Samples = samples
+	}
+	for result, chance := range chances {
+		if result == noRowResult {
+			dist.Odds = append(dist.Odds, Odds{Chance: chance, NoRow: true})
+			continue
+		}
+		dist.Odds = append(dist.Odds, Odds{Result: result, Chance: chance})
+	}
+	sort.Slice(dist.Odds, func(i, j int) bool {
+		if dist.Odds[i].Chance != dist.Odds[j].Chance {
+			return dist.Odds[i].Chance > dist.Odds[j].Chance
+		}
+		return dist.Odds[i].Result < dist.Odds[j].Result
+	})
+	return dist, nil
+}
+
+type oddsState struct {
+	tree *Tree
+	// ctx limits each sample so that a table can't keep sampling busy forever
+	ctx      context.Context
+	samples  int
+	memo     map[string]map[string]float64
+	visiting map[string]bool
+	exact    bool
+}
+
+// table returns the chance of each rendered result from the named table
+func (s *oddsState) table(name string) (map[string]float64, error) {
+	tb, name, err := s.tree.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+	if chances, found := s.memo[name]; found {
+		return chances, nil
+	}
+	// A table that looks itself up can't be followed exactly
+	if s.visiting[name] {
+		return nil, errNotExact
+	}
+	s.visiting[name] = true
+	defer delete(s.visiting, name)
+
+	items, err := itemChances(tb.Table)
+	// The dice are too complex so the whole table is sampled
+	if err == errNotExact {
+		s.exact = false
+		return s.sample(func() (string, error) { return s.tree.GetItem(s.ctx, name) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	chances, err := s.items(items, name)
+	if err != nil {
+		return nil, err
+	}
+	s.memo[name] = chances
+	return chances, nil
+}
+
+// items renders the chance of each item from a table
+func (s *oddsState) items(items map[string]float64, table string) (map[string]float64, error) {
+	chances := map[string]float64{}
+	for item, chance := range items {
+		results, err := s.item(item, table)
+		if err != nil {
+			return nil, err
+		}
+		for result, c := range results {
+			chances[result] += chance * c
+		}
+	}
+	return chances, nil
+}
+
+// item returns the chance of each result from rendering a single item
+func (s *oddsState) item(item, table string) (map[string]float64, error) {
+	if !strings.Contains(item, "{{") {
+		return map[string]float64{item: 1}, nil
+	}
+	chances, err := s.template(item, table)
+	if err == errNotExact {
+		s.exact = false
+		return s.sample(func() (string, error) { return s.tree.renderItem(newGeneration(s.ctx), item, table, nil) })
+	}
+	return chances, err
+}
+
+// template follows a template made up of text, lookups and fudges to the tables it uses
+func (s *oddsState) template(item, table string) (map[string]float64, error) {
+	tmpl, err := s.tree.parseItem(item)
+	if err != nil {
+		return nil, err
+	}
+	chances := map[string]float64{"": 1}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		var part map[string]float64
+		switch n := node.(type) {
+		case *parse.TextNode:
+			part = map[string]float64{string(n.Text): 1}
+		case *parse.ActionNode:
+			part, err = s.action(n, table)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errNotExact
+		}
+		chances, err = combineChances(chances, part, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chances, nil
+}
+
+// action returns the chances for a lookup or fudge of a table named by a string
+func (s *oddsState) action(n *parse.ActionNode, table string) (map[string]float64, error) {
+	if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 {
+		return nil, errNotExact
+	}
+	args := n.Pipe.Cmds[0].Args
+	if len(args) < 2 {
+		return nil, errNotExact
+	}
+	fn, ok := args[0].(*parse.IdentifierNode)
+	if !ok {
+		return nil, errNotExact
+	}
+	strArgs := []string{}
+	for _, arg := range args[1:] {
+		switch a := arg.(type) {
+		case *parse.StringNode:
+			strArgs = append(strArgs, a.Text)
+		case *parse.NumberNode:
+			if !a.IsInt {
+				return nil, errNotExact
+			}
+			strArgs = append(strArgs, strconv.FormatInt(a.Int64, 10))
+		default:
+			return nil, errNotExact
+		}
+	}
+	target := resolvePaths(table, strArgs[0])
+	var chances map[string]float64
+	var err error
+	switch {
+	case fn.Ident == "lookup" && len(strArgs) <= 2:
+		// Modified rolls are rendered to find their odds
+		if _, mod, named, err := parseLookupArgs(lookupArgs(strArgs[1:])); err != nil || !mod.IsZero() || len(named) > 0 {
+			return nil, errNotExact
+		}
+		chances, err = s.table(target)
+	case fn.Ident == "fudge" && len(strArgs) >= 2 && len(strArgs) <= 3:
+		chances, err = s.fudge(target, strArgs[1])
+		strArgs = strArgs[1:]
+	default:
+		return nil, errNotExact
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Several rolls on the same table are joined together. Too many to combine are sampled.
+	times := 1
+	if len(strArgs) == 2 {
+		times = parseRollCount([]interface{}{strArgs[1]})
+	}
+	if times > maxOddsResults {
+		return nil, errNotExact
+	}
+	result := chances
+	for x := 1; x < times; x++ {
+		result, err = combineChances(result, chances, ", ")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// lookupArgs passes arguments to parseLookupArgs as a template would
+func lookupArgs(strArgs []string) []interface{} {
+	args := []interface{}{}
+	for _, arg := range strArgs {
+		args = append(args, arg)
+	}
+	return args
+}
+
+// fudge returns the chances of rolling on a table with different dice
+func (s *oddsState) fudge(table, dicestr string) (map[string]float64, error) {
+	tb, name, err := s.tree.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	fudged := fudgeTable(tb.Table, dicestr)
+	items, err := itemChances(&fudged)
+	if err == errNotExact {
+		s.exact = false
+		return s.sample(func() (string, error) {
+			result, err := fudged.GetItem(s.tree.rand)
+			if err != nil {
+				return "", &TableError{Table: name, Err: err}
+			}
+			return s.tree.renderItem(newGeneration(s.ctx), result.Item, name, nil)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.items(items, name)
+}
+
+// sample estimates the odds of a result by generating it many times
+func (s *oddsState) sample(generate func() (string, error)) (map[string]float64, error) {
+	counts := map[string]int{}
+	for x := 0; x < s.samples; x++ {
+		result, err := generate()
+		var noRow *NoRowError
+		if errors.As(err, &noRow) {
+			result, err = noRowResult, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[result]++
+	}
+	chances := map[string]float64{}
+	for result, count := range counts {
+		chances[result] = float64(count) / float64(s.samples)
+	}
+	return chances, nil
+}
+
+// itemChances returns the chance of each unrendered item from a table. errNotExact
+// is returned when the dice of the table are too complex to work out.
+func itemChances(table Table) (map[string]float64, error) {
+	chances := map[string]float64{}
+	switch tb := table.(type) {
+	case *RollingTable:
+		if tb.diceErr != nil {
+			return nil, tb.diceErr
+		}
+		dist, err := tb.dice.Distribution()
+		if err != nil {
+			return nil, errNotExact
+		}
+		// Rolls that aren't on the table don't give an item
+		for roll, chance := range dist {
+			interval, found := tb.interval(roll)
+			if !found {
+				chances[noRowResult] += chance
+				continue
+			}
+			chances[interval.item] += chance
+		}
+	case *DeckTable:
+		return itemChances(&tb.WeightedTable)
+	case *WeightedTable:
+		total := float64(tb.totalWeight())
+		for i, item := range tb.items {
+			if weight := tb.weight(i); weight > 0 {
+				chances[item] += float64(weight) / total
+			}
+		}
+	default:
+		items := table.AllItems()
+		for _, item := range items {
+			chances[item] += 1 / float64(len(items))
+		}
+	}
+	return chances, nil
+}
+
+// combineChances joins every result of a with every result of b. A result joined with a
+// roll that has no row has no row either, as the item fails.
+func combineChances(a, b map[string]float64, sep string) (map[string]float64, error) {
+	if len(a)*len(b) > maxOddsResults {
+		return nil, errNotExact
+	}
+	chances := map[string]float64{}
+	for ar, ac := range a {
+		for br, bc := range b {
+			if ar == noRowResult || br == noRowResult {
+				chances[noRowResult] += ac * bc
+				continue
+			}
+			chances[ar+sep+br] += ac * bc
+		}
+	}
+	return chances, nil
+}
@@ -0,0 +1,135 @@
+package randomtable
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const oddsTest = `
+| 2d6 | Weather |
+| --- | ------- |
+| 2-6 | Rain    |
+| 7   | Wind    |
+| 8-12| Sun     |
+
+| Monster | weight |
+| ------- | ------ |
+| Goblin  | 3      |
+| Dragon  | 1      |
+
+| Day                                        |
+| ------------------------------------------ |
+| {{lookup "weather"}} and {{lookup "monster"}} |
+
+| Mystery                       |
+| ----------------------------- |
+| {{if true}}{{lookup "monster"}}{{end}} |
+`
+
+func TestDistribution(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(oddsTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	cases := []struct {
+		table    string
+		expected map[string]float64
+		exact    bool
+	}{
+		{
+			table:    "weather",
+			expected: map[string]float64{"Rain": 15.0 / 36, "Wind": 6.0 / 36, "Sun": 15.0 / 36},
+			exact:    true,
+		},
+		{
+			table:    "monster",
+			expected: map[string]float64{"Goblin": 0.75, "Dragon": 0.25},
+			exact:    true,
+		},
+		{
+			table:    "day",
+			expected: map[string]float64{"Wind and Dragon": 6.0 / 36 * 0.25},
+			exact:    true,
+		},
+		{
+			table:    "mystery",
+			expected: map[string]float64{"Goblin": 0.75},
+			exact:    false,
+		},
+	}
+	for _, c := range cases {
+		dist, err := tree.DistributionWithSamples(c.table, 2000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dist.Exact != c.exact {
+			t.Errorf("%s: Expected exact to be %v", c.table, c.exact)
+		}
+		// Sampled odds only need to be close
+		tolerance := 1e-9
+		if !c.exact {
+			tolerance = 0.05
+		}
+		for result, expected := range c.expected {
+			actual := -1.0
+			for _, o := range dist.Odds {
+				if o.Result == result {
+					actual = o.Chance
+				}
+			}
+			if math.Abs(actual-expected) > tolerance {
+				t.Errorf("%s: Expected %s to have a chance of %v but got %v", c.table, result, expected, actual)
+			}
+		}
+	}
+}
+
+const noRowOddsTest = `
+| 1d4 | Gap |
+| --- | --- |
+| 1-3 | Orc |
+
+| Camp                   |
+| ---------------------- |
+| {{lookup "gap"}} camp |
+
+| Single |
+| ------ |
+| x      |
+
+| Many                              |
+| --------------------------------- |
+| {{lookup "single" 1000000000}}     |
+`
+
+func TestDistributionNoRow(t *testing.T) {
+	tree := NewTree()
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(noRowOddsTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	for table, result := range map[string]string{"gap": "Orc", "camp": "Orc camp"} {
+		dist, err := tree.Distribution(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Odds{{Result: result, Chance: 0.75}, {Chance: 0.25, NoRow: true}}
+		if !reflect.DeepEqual(dist.Odds, expected) || !dist.Exact {
+			t.Errorf("%s: Expected %v but got %+v", table, expected, dist)
+		}
+	}
+	// Too many lookups to combine are sampled, which stops at the limit on lookups
+	start := time.Now()
+	if _, err := tree.DistributionWithSamples("many", 10); err == nil {
+		t.Error("Expected an error for a billion lookups")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the odds of many lookups to stop soon but it took %s", elapsed)
+	}
+}
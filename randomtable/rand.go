@@ -0,0 +1,72 @@
+package randomtable
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The characters that sprig's random string functions pick from
+const (
+	alphaChars   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	numericChars = "0123456789"
+	// asciiChars are the printable ascii characters, from space to ~
+	asciiChars = " !\"#$%&'()*+,-./" + numericChars + ":;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+)
+
+// lockedSource allows a single source of randomness to be shared between goroutines
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// NewRand returns a source of randomness seeded with the given seed that is safe for concurrent use
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// newTimeRand returns a source of randomness seeded from the current time
+func newTimeRand() *rand.Rand {
+	return NewRand(time.Now().UnixNano())
+}
+
+// randString makes a string of count characters picked from chars
+func randString(r *rand.Rand, count int, chars string) string {
+	b := strings.Builder{}
+	for i := 0; i < count; i++ {
+		b.WriteByte(chars[r.Intn(len(chars))])
+	}
+	return b.String()
+}
+
+// randUUID makes a version 4 uuid like sprig's uuidv4
+func randUUID(r *rand.Rand) string {
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = byte(r.Intn(256))
+	}
+	// Set the version to 4 and the variant to RFC 4122
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,43 @@
+package randomtable
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRandString(t *testing.T) {
+	if len(asciiChars) != 95 {
+		t.Errorf("Expected the 95 printable ascii characters but got %d", len(asciiChars))
+	}
+	r := NewRand(1)
+	for _, chars := range []string{alphaChars, numericChars, asciiChars} {
+		s := randString(r, 50, chars)
+		if len(s) != 50 {
+			t.Errorf("Expected 50 characters but got %q", s)
+		}
+		for _, c := range s {
+			if !strings.ContainsRune(chars, c) {
+				t.Errorf("Expected %q to only use %q", s, chars)
+			}
+		}
+	}
+	if s := randString(r, -1, alphaChars); s != "" {
+		t.Errorf("Expected nothing for a negative count but got %q", s)
+	}
+}
+
+func TestRandUUID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	r := NewRand(1)
+	first := randUUID(r)
+	if !uuid.MatchString(first) {
+		t.Errorf("Expected a version 4 uuid but got %s", first)
+	}
+	if second := randUUID(r); second == first {
+		t.Errorf("Expected a different uuid each time but got %s twice", first)
+	}
+	if again := randUUID(NewRand(1)); again != first {
+		t.Errorf("Expected the same seed to give %s but got %s", first, again)
+	}
+}
@@ -0,0 +1,265 @@
+package randomtable
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// tableFuncs are the template functions that take the name of a table as their first argument.
+// The value is true for functions that render an item from the table.
+var tableFuncs = map[string]bool{
+	"lookup":      true,
+	"fudge":       true,
+	"draw":        true,
+	"norepeat":    true,
+	"lookupRow":   true,
+	"sticky":      true,
+	"shuffleDeck": false,
+	"remaining":   false,
+}
+
+// Reference is a use of one table by another, either by a template function or a link
+type Reference struct {
+	// Table is the table making the reference
+	Table string `json:"table"`
+	// File is the markdown file the table was found in
+	File string `json:"file,omitempty"`
+	// Line is the first line of the table in the file
+	Line int `json:"line,omitempty"`
+	// Function is the template function that was used, or "link" for links
+	Function string `json:"function"`
+	// Target is the name of the table being used with relative paths resolved
+	Target string `json:"target"`
+	// Error is set when the target can't be found
+	Error string `json:"error,omitempty"`
+}
+
+// References returns every reference made by the tables in the tree, sorted by table.
+// Templates are checked without being rendered so that every branch of an if and
+// every argument to pick is included. Names that are only known once the template
+// is run, such as ones built with print, can't be found.
+func (t *Tree) References() []Reference {
+	refs := []Reference{}
+	t.tables.Walk(func(key string, value interface{}) error {
+		switch tb := value.(type) {
+		case TableNode:
+			seen := map[Reference]bool{}
+			for _, item := range tb.AllItems() {
+				itemRefs, err := t.itemReferences(item, key)
+				// Items that don't parse are reported when they are rendered
+				if err != nil {
+					continue
+				}
+				for _, ref := range itemRefs {
+					ref.File = tb.Source.File
+					ref.Line = tb.Source.Line
+					if !seen[ref] {
+						seen[ref] = true
+						refs = append(refs, t.checkReference(ref))
+					}
+				}
+			}
+		case LinkNode:
+			ref := Reference{Table: key, File: tb.Source.File, Line: tb.Source.Line, Function: "link", Target: strings.ToLower(tb.Link)}
+			refs = append(refs, t.checkReference(ref))
+		}
+		return nil
+	})
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Table < refs[j].Table
+	})
+	return refs
+}
+
+// MissingReferences returns the references to tables that can't be found
+func (t *Tree) MissingReferences() []Reference {
+	missing := []Reference{}
+	for _, ref := range t.References() {
+		if ref.Error != "" {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+// hasMissingReference is true when an item uses a table that can't be found
+func (t *Tree) hasMissingReference(item, table string) bool {
+	refs, _ := t.itemReferences(item, table)
+	for _, ref := range refs {
+		if t.checkReference(ref).Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tree) checkReference(ref Reference) Reference {
+	if _, _, err := t.GetTable(ref.Target); err != nil {
+		ref.Error = err.Error()
+	}
+	return ref
+}
+
+// itemReferences returns the tables that an item uses by name. Relative paths are resolved
+// against the table the item is on.
+func (t *Tree) itemReferences(item, table string) ([]Reference, error) {
+	if !strings.Contains(item, "{{") {
+		return nil, nil
+	}
+	tmpl, err := t.parseItem(item)
+	if err != nil {
+		return nil, err
+	}
+	refs := []Reference{}
+	walkCommands(tmpl.Tree.Root, func(cmd *parse.CommandNode) {
+		if len(cmd.Args) < 2 {
+			return
+		}
+		fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return
+		}
+		if _, found := tableFuncs[fn.Ident]; !found {
+			return
+		}
+		if name, ok := cmd.Args[1].(*parse.StringNode); ok {
+			target := strings.ReplaceAll(strings.ToLower(resolvePaths(table, name.Text)), " ", "")
+			refs = append(refs, Reference{Table: table, Function: fn.Ident, Target: target})
+		}
+	})
+	return refs, nil
+}
+
+// walkCommands calls visit for every command in the template, including those nested in
+// parentheses and the branches of if, range and with
+func walkCommands(node parse.Node, visit func(*parse.CommandNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkCommands(child, visit)
+		}
+	case *parse.ActionNode:
+		walkCommands(n.Pipe, visit)
+	case *parse.TemplateNode:
+		walkCommands(n.Pipe, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkCommands(cmd, visit)
+		}
+	case *parse.ChainNode:
+		walkCommands(n.Node, visit)
+	case *parse.CommandNode:
+		visit(n)
+		for _, arg := range n.Args {
+			walkCommands(arg, visit)
+		}
+	}
+}
+
+func walkBranch(n *parse.BranchNode, visit func(*parse.CommandNode)) {
+	walkCommands(n.Pipe, visit)
+	walkCommands(n.List, visit)
+	walkCommands(n.ElseList, visit)
+}
+
+// Graph holds every table in the tree and the references between them
+type Graph struct {
+	Tables     []GraphTable `json:"tables"`
+	References []Reference  `json:"references"`
+}
+
+// GraphTable is a table or link in the dependency graph
+type GraphTable struct {
+	Name   string `json:"name"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+	Link   bool   `json:"link,omitempty"`
+}
+
+// Graph returns the dependency graph of all the tables in the tree
+func (t *Tree) Graph() Graph {
+	g := Graph{Tables: []GraphTable{}, References: t.References()}
+	t.tables.Walk(func(key string, value interface{}) error {
+		switch tb := value.(type) {
+		case TableNode:
+			g.Tables = append(g.Tables, GraphTable{Name: key, File: tb.Source.File, Line: tb.Source.Line, Hidden: tb.Hidden})
+		case LinkNode:
+			g.Tables = append(g.Tables, GraphTable{Name: key, File: tb.Source.File, Line: tb.Source.Line, Link: true})
+		}
+		return nil
+	})
+	sort.Slice(g.Tables, func(i, j int) bool {
+		return g.Tables[i].Name < g.Tables[j].Name
+	})
+	return g
+}
+
+// JSON returns the graph as indented JSON
+func (g Graph) JSON() (string, error) {
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// DOT returns the graph in the Graphviz DOT language. Links are drawn as dashed edges
+// and tables that can't be found are drawn in red.
+func (g Graph) DOT() string {
+	b := &strings.Builder{}
+	b.WriteString("digraph makemea {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, table := range g.Tables {
+		attrs := []string{}
+		if table.Hidden {
+			attrs = append(attrs, "style=dotted")
+		}
+		if table.Link {
+			attrs = append(attrs, "shape=ellipse")
+		}
+		writeDOTNode(b, table.Name, attrs)
+	}
+	missing := map[string]bool{}
+	for _, ref := range g.References {
+		if ref.Error != "" && !missing[ref.Target] {
+			missing[ref.Target] = true
+			writeDOTNode(b, ref.Target, []string{"color=red"})
+		}
+	}
+	for _, ref := range g.References {
+		attrs := []string{fmt.Sprintf("label=%q", ref.Function)}
+		if ref.Function == "link" {
+			attrs = []string{"style=dashed"}
+		}
+		if ref.Error != "" {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(b, "  %q -> %q [%s];\n", ref.Table, ref.Target, strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeDOTNode(b *strings.Builder, name string, attrs []string) {
+	if len(attrs) == 0 {
+		fmt.Fprintf(b, "  %q;\n", name)
+		return
+	}
+	fmt.Fprintf(b, "  %q [%s];\n", name, strings.Join(attrs, ", "))
+}
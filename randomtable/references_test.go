@@ -0,0 +1,63 @@
+package randomtable
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const referencesTest = `
+# Places
+
+| Town |
+| ---- |
+| {{if eq (roll "1d2") "3"}}{{lookup "./missing"}}{{end}} |
+| {{pick (lookup "./town") (fudge "nowhere" "1d4")}}       |
+
+[capital](places/town)
+
+[lost](places/atlantis)
+`
+
+func TestReferences(t *testing.T) {
+	tree := NewTree().WithFile("places.md")
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert(bytes.NewBufferString(referencesTest).Bytes(), &buf); err != nil {
+		t.Error(err)
+	}
+	missing := map[string]string{}
+	for _, ref := range tree.MissingReferences() {
+		if ref.File != "places.md" {
+			t.Errorf("Expected the reference to %s to be from places.md but got %q", ref.Target, ref.File)
+		}
+		missing[ref.Target] = ref.Table
+	}
+	expected := map[string]string{
+		"places/missing":  "places/town",
+		"nowhere":         "places/town",
+		"places/atlantis": "places/lost",
+	}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing references %v but got %v", expected, missing)
+	}
+	if refs := len(tree.References()); refs != 5 {
+		t.Errorf("Expected 5 references but got %d", refs)
+	}
+
+	graph := tree.Graph()
+	if len(graph.Tables) != 3 {
+		t.Errorf("Expected 3 tables in the graph but got %v", graph.Tables)
+	}
+	dot := graph.DOT()
+	for _, line := range []string{
+		`"places/capital" -> "places/town" [style=dashed];`,
+		`"places/town" -> "places/town" [label="lookup"];`,
+		`"nowhere" [color=red];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected the graph to contain %s but got\n%s", line, dot)
+		}
+	}
+}
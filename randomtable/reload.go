@@ -0,0 +1,159 @@
+package randomtable
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/trie"
+)
+
+// TableChanges lists the tables that are different between two trees
+type TableChanges struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty is true when no tables changed
+func (c TableChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// WithoutFiles returns a new tree holding every table except those found in the given files.
+// The returned tree has its own tables, so the tables from the files can be parsed again and
+// added to it while this tree is still in use. Decks start out fresh.
+func (t Tree) WithoutFiles(files ...string) Tree {
+	skip := map[string]bool{}
+	for _, file := range files {
+		skip[file] = true
+	}
+	tables := trie.NewPathTrie()
+	t.tables.Walk(func(key string, value interface{}) error {
+		if !skip[sourceOf(value).File] {
+			tables.Put(key, value)
+		}
+		return nil
+	})
+	issues := []Issue{}
+	for _, issue := range *t.loadIssues {
+		if !skip[issue.File] {
+			issues = append(issues, issue)
+		}
+	}
+	t.tables = tables
+	t.loadIssues = &issues
+	t.decks = newDeckStore()
+	t.templates = newTemplateCache(t.templates.funcs)
+	return t
+}
+
+// HasDuplicates is true when more than one table was added with the same name. Which of the
+// tables is kept depends on the order the files were loaded in.
+func (t *Tree) HasDuplicates() bool {
+	for _, issue := range *t.loadIssues {
+		if issue.Kind == IssueDuplicateTable {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns the tables that were added, removed or changed in the newer tree. A table
+// that only moved within its file isn't changed.
+func (t *Tree) Changes(newer *Tree) TableChanges {
+	changes := TableChanges{}
+	t.tables.Walk(func(key string, value interface{}) error {
+		newValue := newer.tables.Get(key)
+		if newValue == nil {
+			changes.Removed = append(changes.Removed, key)
+		} else if !sameNode(value, newValue) {
+			changes.Changed = append(changes.Changed, key)
+		}
+		return nil
+	})
+	newer.tables.Walk(func(key string, value interface{}) error {
+		if t.tables.Get(key) == nil {
+			changes.Added = append(changes.Added, key)
+		}
+		return nil
+	})
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	return changes
+}
+
+// sameNode compares two nodes of the tree, ignoring where they were found
+func sameNode(a, b interface{}) bool {
+	switch na := a.(type) {
+	case TableNode:
+		nb, ok := b.(TableNode)
+		return ok && na.Hidden == nb.Hidden && reflect.DeepEqual(contents(na.Table), contents(nb.Table))
+	case LinkNode:
+		nb, ok := b.(LinkNode)
+		return ok && na.Link == nb.Link && reflect.DeepEqual(na.Args, nb.Args)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Files returns every markdown file that tables in the tree were found in
+func (t *Tree) Files() []string {
+	seen := map[string]bool{}
+	files := []string{}
+	t.tables.Walk(func(key string, value interface{}) error {
+		file := sourceOf(value).File
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+// Hash returns a hash of every table in the tree. Trees with the same tables have the same
+// hash, wherever the tables were found.
+func (t *Tree) Hash() string {
+	keys := []string{}
+	nodes := map[string]interface{}{}
+	t.tables.Walk(func(key string, value interface{}) error {
+		keys = append(keys, key)
+		nodes[key] = value
+		return nil
+	})
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		switch node := nodes[key].(type) {
+		case TableNode:
+			desc := contents(node.Table)
+			fmt.Fprintf(h, "%s %s %q hidden=%t\n", key, desc.Kind, desc.Dice, node.Hidden)
+			// Rows aren't always listed in the same order
+			lines := []string{}
+			for _, row := range desc.Rows {
+				line, _ := json.Marshal(row)
+				lines = append(lines, string(line))
+			}
+			sort.Strings(lines)
+			fmt.Fprintln(h, strings.Join(lines, "\n"))
+		case LinkNode:
+			fmt.Fprintf(h, "%s -> %s %v\n", key, node.Link, node.Args)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contents describes a table without where its rows were found
+func contents(table Table) Description {
+	desc := table.Describe()
+	for i := range desc.Rows {
+		desc.Rows[i].Source = Source{}
+	}
+	return desc
+}
@@ -0,0 +1,83 @@
+package randomtable
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithoutFiles(t *testing.T) {
+	files := map[string]string{
+		"a.md": "# Town\n\n| Name |\n| --- |\n| Ann |\n\n| Job |\n| --- |\n| Smith |\n",
+		"b.md": "| River |\n| --- |\n| Wide |\n",
+	}
+	old := NewTree()
+	if err := loadFiles(old, files, []string{"a.md", "b.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if files := old.Files(); !reflect.DeepEqual(files, []string{"a.md", "b.md"}) {
+		t.Errorf("Expected tables from a.md and b.md but got %v", files)
+	}
+
+	newer := old.WithoutFiles("a.md")
+	changed := map[string]string{"a.md": "# Town\n\n| Name |\n| --- |\n| Bob |\n\n| Sign |\n| --- |\n| Inn |\n"}
+	if err := loadFiles(newer, changed, []string{"a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := TableChanges{
+		Added:   []string{"town/sign"},
+		Removed: []string{"town/job"},
+		Changed: []string{"town/name"},
+	}
+	if changes := old.Changes(&newer); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, changes)
+	}
+
+	// The old tree is left as it was
+	item, err := old.GetItem(context.Background(), "town/name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != "Ann" {
+		t.Errorf("Expected the old tree to keep Ann but got %s", item)
+	}
+	if _, _, err := newer.GetTable("river"); err != nil {
+		t.Errorf("Expected the new tree to keep the tables from b.md: %v", err)
+	}
+	if old.Hash() == newer.Hash() {
+		t.Errorf("Expected the changed tables to have a different hash")
+	}
+	if changes := newer.Changes(&newer); !changes.Empty() {
+		t.Errorf("Expected no changes but got %+v", changes)
+	}
+}
+
+func TestWithoutFilesMergeRows(t *testing.T) {
+	files := map[string]string{
+		"a.md": "| X |\n| --- |\n| from a |\n",
+		"b.md": "| X |\n| --- |\n| from b |\n",
+	}
+	old := NewTree().WithDuplicatePolicy(DuplicateMergeRows)
+	if err := loadFiles(old, files, []string{"a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	newer := old.WithoutFiles("b.md")
+	if err := loadFiles(newer, files, []string{"b.md"}); err != nil {
+		t.Fatal(err)
+	}
+	tb, _, err := newer.GetTable("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tb.AllItems(), []string{"from a", "from b"}) {
+		t.Errorf("Expected the new tree to merge the rows but got %v", tb.AllItems())
+	}
+	// The rows are merged into a copy rather than the table the old tree is still using
+	tb, _, err = old.GetTable("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tb.AllItems(), []string{"from a"}) {
+		t.Errorf("Expected the old tree to keep its rows but got %v", tb.AllItems())
+	}
+}
@@ -1,20 +1,23 @@
 package randomtable
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/justinian/dice"
-	log "github.com/sirupsen/logrus"
 	"github.com/yuin/goldmark/ast"
 	gast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
 
 const ROLL_TABLE_NAME = "ROLLTABLECOLUMN"
+const WEIGHT_TABLE_NAME = "WEIGHTTABLECOLUMN"
+const DECK_TABLE_NAME = "DECKTABLECOLUMN"
 
 type randomTableRenderer struct {
 	nodeRendererFuncsTmp map[ast.NodeKind]renderer.NodeRendererFunc
@@ -22,6 +25,8 @@ type randomTableRenderer struct {
 	namespace            []string
 	depth                int
 	currentTableNames    []string //Names of the tables being rendered
+	currentTables        []Table  // Tables that rows are added to, by column
+	currentRowTable      *RowTable
 }
 
 // Push a string into the namespace
@@ -64,6 +69,50 @@ func NewRandomTableRenderer(tree Tree) renderer.NodeRenderer {
 	return r
 }
 
+// source returns the lines of the markdown file that the node and its children cover
+func (r *randomTableRenderer) source(n ast.Node, source []byte) Source {
+	start, stop := -1, -1
+	addSegment := func(seg text.Segment) {
+		if start == -1 || seg.Start < start {
+			start = seg.Start
+		}
+		if seg.Stop > stop {
+			stop = seg.Stop
+		}
+	}
+	ast.Walk(n, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := node.(type) {
+		case *ast.Text:
+			addSegment(c.Segment)
+		case *ast.FencedCodeBlock:
+			if c.Info != nil {
+				addSegment(c.Info.Segment)
+			}
+		}
+		if node.Type() == ast.TypeBlock {
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				addSegment(lines.At(i))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	src := Source{File: r.tree.file}
+	if start == -1 {
+		return src
+	}
+	src.Line = bytes.Count(source[:start], []byte("\n")) + 1
+	src.EndLine = bytes.Count(source[:stop-1], []byte("\n")) + 1
+	if _, fenced := n.(*ast.FencedCodeBlock); fenced {
+		// The closing fence isn't part of the block's lines
+		src.EndLine++
+	}
+	return src
+}
+
 func (r *randomTableRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 	reg.Register(gast.KindTableHeader, r.renderTableHeader)
 	reg.Register(gast.KindTableRow, r.renderTableRow)
@@ -78,8 +127,19 @@ func (r *randomTableRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegiste
 func (r *randomTableRenderer) parseHeaderCell(cell ast.Node, col int, source []byte) string {
 	text := cell.Text(source)
 	diceRoll := ""
+	header := strings.TrimSpace(string(text))
+	// A weight column gives the odds for the items in all other columns
+	if header == WEIGHT_COLUMN_NAME && numericColumn(cell.Parent(), col, source, false) {
+		r.currentTableNames[col] = WEIGHT_TABLE_NAME
+		return diceRoll
+	}
+	// A deck column gives the number of copies of each item in the deck
+	if header == DECK_COLUMN_NAME && numericColumn(cell.Parent(), col, source, true) {
+		r.currentTableNames[col] = DECK_TABLE_NAME
+		return diceRoll
+	}
 	// If we find a dice string, all other columns are for rolling
-	if (dice.StdRoller{}.Pattern().Match(text)) {
+	if IsDice(string(text)) {
 		diceRoll = string(text)
 		r.currentTableNames[col] = ROLL_TABLE_NAME
 	} else {
@@ -88,12 +148,42 @@ func (r *randomTableRenderer) parseHeaderCell(cell ast.Node, col int, source []b
 	}
 	return diceRoll
 }
+
+// numericColumn reports whether the column of the rows after the header holds numbers, so
+// that a column headed weight or deck gives the weights or copies for the other columns
+// rather than being a column of items itself. A column holds numbers unless it has text
+// and none of its cells are whole numbers, so a weight that's mistyped is still reported
+// by Validate. Empty cells count as numbers when allowEmpty is true.
+func numericColumn(header ast.Node, col int, source []byte, allowEmpty bool) bool {
+	numbers, text := 0, 0
+	for row := header.NextSibling(); row != nil; row = row.NextSibling() {
+		cell := row.FirstChild()
+		for x := 0; x < col && cell != nil; x++ {
+			cell = cell.NextSibling()
+		}
+		if cell == nil {
+			continue
+		}
+		value := strings.TrimSpace(string(cell.Text(source)))
+		if _, err := strconv.Atoi(value); err == nil {
+			numbers++
+		} else if value != "" || !allowEmpty {
+			text++
+		}
+	}
+	return numbers > 0 || text == 0
+}
+
 func (r *randomTableRenderer) renderTableHeader(writer util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
 		r.currentTableNames = make([]string, n.ChildCount())
+		r.currentTables = make([]Table, n.ChildCount())
+		r.currentRowTable = nil
+		headers := make([]string, n.ChildCount())
 		childNum := 0
 		// Header --Child--> 1st Header Cell --Sibling--> Nth Header Cell
 		diceRoll := r.parseHeaderCell(n.FirstChild(), childNum, source)
+		headers[childNum] = strings.TrimSpace(string(n.FirstChild().Text(source)))
 		sib := n.FirstChild().NextSibling()
 		childNum++
 		for sib != nil {
@@ -101,44 +191,95 @@ func (r *randomTableRenderer) renderTableHeader(writer util.BufWriter, source []
 			if newRoll != "" {
 				diceRoll = newRoll
 			}
+			headers[childNum] = strings.TrimSpace(string(sib.Text(source)))
 			sib = sib.NextSibling()
 			childNum++
 		}
+		weighted := false
+		deck := false
 		for _, name := range r.currentTableNames {
+			if name == WEIGHT_TABLE_NAME {
+				weighted = true
+			}
+			if name == DECK_TABLE_NAME {
+				deck = true
+			}
+		}
+		rowHeaders := []string{}
+		rowTables := []string{}
+		created := []Table{}
+		src := r.source(n.Parent(), source)
+		for x, name := range r.currentTableNames {
 			// No table needs to be made for this column
-			if name == ROLL_TABLE_NAME {
+			if name == ROLL_TABLE_NAME || name == WEIGHT_TABLE_NAME || name == DECK_TABLE_NAME {
 				continue
 			}
-			if diceRoll == "" {
-				t := NewRandomTable()
-				r.tree.AddTable(name, &t, false)
-			} else {
-				t := NewRollingTable(diceRoll).WithLogger(
-					log.WithFields(log.Fields{"table": name}))
-				r.tree.AddTable(name, &t, false)
+			table := r.newColumnTable(diceRoll, weighted, deck)
+			added, err := r.tree.addTable(name, table, false, src)
+			if err != nil {
+				return ast.WalkStop, err
+			}
+			r.currentTables[x] = added
+			rowHeaders = append(rowHeaders, headers[x])
+			rowTables = append(rowTables, name)
+			created = append(created, table)
+		}
+		// Every column shares the rows so a whole row can be selected at once
+		if len(rowTables) > 0 {
+			rows := NewRowTable(rowHeaders, rowTables, r.newColumnTable(diceRoll, weighted, deck))
+			r.currentRowTable = &rows
+			for x, name := range rowTables {
+				r.tree.addRowTable(name, r.currentRowTable, created[x])
 			}
 		}
 	}
 	return ast.WalkContinue, nil
 }
+
+// newColumnTable creates the kind of table that the header describes
+func (r *randomTableRenderer) newColumnTable(diceRoll string, weighted, deck bool) Table {
+	if diceRoll == "" && deck {
+		t := NewDeckTable()
+		return &t
+	} else if diceRoll == "" && weighted {
+		t := NewWeightedTable()
+		return &t
+	} else if diceRoll == "" {
+		t := NewRandomTable()
+		return &t
+	}
+	t := NewRollingTable(diceRoll)
+	return &t
+}
+
 func (r *randomTableRenderer) renderDefinitionTerm(writer util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
 		t := NewRandomTable()
 		name := r.Name(string(n.Text(source)))
-		r.tree.AddTable(name, &t, false)
+		// The table covers the term and each of its descriptions
+		last := n
+		for sib := n.NextSibling(); sib != nil && sib.Kind() == gast.KindDefinitionDescription; sib = sib.NextSibling() {
+			last = sib
+		}
+		src := r.source(n, source)
+		src.EndLine = r.source(last, source).EndLine
+		added, err := r.tree.addTable(name, &t, false, src)
+		if err != nil {
+			return ast.WalkStop, err
+		}
 		r.currentTableNames = []string{name}
+		r.currentTables = []Table{added}
 	}
 	return ast.WalkContinue, nil
 }
 func (r *randomTableRenderer) renderDefinitionDescription(writer util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
 		text := string(n.Text(source))
-		tableName := r.currentTableNames[0]
-		table, _,err := r.tree.GetTable(tableName)
-		if err != nil {
-				return ast.WalkContinue, fmt.Errorf("unable to find table: %s", tableName)
-			}
-		table.AddItem(text)
+		table := r.currentTables[0]
+		addSourcedItem(table, r.source(n, source), func() error {
+			table.AddItem(text)
+			return nil
+		})
 	}
 	return ast.WalkContinue, nil
 }
@@ -146,11 +287,19 @@ func (r *randomTableRenderer) renderDefinitionDescription(writer util.BufWriter,
 func (r *randomTableRenderer) renderTableRow(writer util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
 		rollColumn := -1
+		weightColumn := -1
+		deckColumn := -1
 		// Match the row cell to the header cell to determine which table we are referencing
 		for x, name := range r.currentTableNames {
 			if name == ROLL_TABLE_NAME {
 				rollColumn = x
 			}
+			if name == WEIGHT_TABLE_NAME {
+				weightColumn = x
+			}
+			if name == DECK_TABLE_NAME {
+				deckColumn = x
+			}
 		}
 
 		// Get the text from each cell in the row and hold it in an array
@@ -168,34 +317,38 @@ func (r *randomTableRenderer) renderTableRow(writer util.BufWriter, source []byt
 			childCount++
 			sib = sib.NextSibling()
 		}
-		// Take each column item and add it to the corresponding table
-		// Checks to see if we have dice rolls associated with the table
-		for x, text := range columns {
-			// we don't need to directly add the roll column to any table
-			// just us the value for the other columns
-			if x == rollColumn {
-				continue
-			}
-			tableName := r.currentTableNames[x]
-			table, _, err := r.tree.GetTable(tableName)
-
-			if err != nil {
-				return ast.WalkContinue, fmt.Errorf("unable to find table: %s", tableName)
-			}
+		// The dice, weight or deck column says how each item is added to its table
+		addItem := func(table Table, text string) error {
 			// Not a rolling table
-			if rollColumn == -1 {
+			if rollColumn == -1 && deckColumn != -1 {
+				dt, ok := table.(*DeckTable)
+				if !ok {
+					return errors.New("not a deck table")
+				}
+				dt.AddCard(text, columns[deckColumn])
+			} else if rollColumn == -1 && weightColumn != -1 {
+				wt, ok := table.(*WeightedTable)
+				if !ok {
+					return errors.New("not a weighted table")
+				}
+				wt.AddWeightedItem(text, columns[weightColumn])
+			} else if rollColumn == -1 {
 				table.AddItem(text)
 
 			} else { // This is a rolling table and we need to use the string from the dice column
+				rt, ok := table.(*RollingTable)
+				if !ok {
+					return errors.New("not a rolling table")
+				}
 				roll := columns[rollColumn]
 				// A single number will match this row
 				singleitem, _ := regexp.MatchString("^[0-9]+$", roll)
 				if singleitem {
 					r, err := strconv.Atoi(roll)
 					if err != nil {
-						return ast.WalkContinue, err
+						return err
 					}
-					table.AddItem(text, r)
+					rt.AddRange(text, r, r)
 				}
 				// a range of numbers will match this row
 				matchRange, _ := regexp.MatchString("^[0-9]+-[0-9]+$", roll)
@@ -203,11 +356,34 @@ func (r *randomTableRenderer) renderTableRow(writer util.BufWriter, source []byt
 					numRange := strings.Split(roll, "-")
 					start, _ := strconv.Atoi(numRange[0])
 					end, _ := strconv.Atoi(numRange[1])
-					for r := start; r <= end; r++ {
-						table.AddItem(text, r)
-					}
+					rt.AddRange(text, start, end)
 				}
 			}
+			return nil
+		}
+		// Take each column item and add it to the corresponding table
+		// Checks to see if we have dice rolls associated with the table
+		cells := []string{}
+		rowSource := r.source(n, source)
+		for x, text := range columns {
+			// we don't need to directly add the roll column to any table
+			// just us the value for the other columns
+			if x == rollColumn || x == weightColumn || x == deckColumn {
+				continue
+			}
+			tableName := r.currentTableNames[x]
+			table := r.currentTables[x]
+			if err := addSourcedItem(table, rowSource, func() error { return addItem(table, text) }); err != nil {
+				return ast.WalkContinue, fmt.Errorf("unable to add to table %s: %w", tableName, err)
+			}
+			cells = append(cells, text)
+		}
+		// The selector holds the index of the row rather than the text of a cell
+		if r.currentRowTable != nil {
+			index := r.currentRowTable.AddRow(cells)
+			if err := addItem(r.currentRowTable.selector, strconv.Itoa(index)); err != nil {
+				return ast.WalkContinue, err
+			}
 		}
 
 	}
@@ -277,8 +453,15 @@ func (r *randomTableRenderer) renderFencedCodeBlock(writer util.BufWriter, sourc
 		for _, line := range n.Lines().Sliced(0, n.Lines().Len()) {
 			result += string(line.Value(source))
 		}
-		t.AddItem(result)
-		r.tree.AddTable(title, &t, hidden)
+		src := r.source(n, source)
+		added, err := r.tree.addTable(title, &t, hidden, src)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		addSourcedItem(added, src, func() error {
+			added.AddItem(result)
+			return nil
+		})
 	}
 
 	return ast.WalkContinue, nil
@@ -294,7 +477,9 @@ func (r *randomTableRenderer) renderLink(writer util.BufWriter, source []byte, n
 		if strings.HasPrefix(url, "http") {
 			return ast.WalkContinue, nil
 		}
-		r.tree.AddLink(r.Name(label), url)
+		if err := r.tree.addLink(r.Name(label), url, r.source(n, source)); err != nil {
+			return ast.WalkStop, err
+		}
 	}
 	return ast.WalkContinue, nil
 }
@@ -1,109 +1,200 @@
 package randomtable
 
 import (
-	"strconv"
+	"math/rand"
+	"sort"
 
 	"github.com/awwithro/makemea/util"
-	"github.com/justinian/dice"
-	"github.com/olekukonko/tablewriter"
-	log "github.com/sirupsen/logrus"
 )
 
 type RollingTable struct {
-	items   map[int]string
-	dicestr string
-	log     log.Entry
+	// rows in the order they were added with the rolls that were given for them
+	rows []rollInterval
+	// intervals are sorted and don't overlap. A later row takes the rolls it shares with
+	// an earlier row.
+	intervals []rollInterval
+	dicestr   string
+	dice      Dice
+	// set when the dice string can't be parsed
+	diceErr error
+	// rolls that were taken from a row by a later row
+	replaced []replacedRolls
+	rowSources
 }
 
-func (r *RollingTable) GetItem() string {
-	result, _, _ := dice.Roll(r.dicestr)
-	return r.items[result.Int()]
+// rollInterval is an item and the rolls that select it
+type rollInterval struct {
+	Range
+	item string
+	// row is the index of the row the item was added by
+	row int
 }
 
+// replacedRolls records that the rolls for an item were given to a later item
+type replacedRolls struct {
+	Range
+	item string
+	by   string
+}
+
+func (r *RollingTable) GetItem(rnd *rand.Rand) (Result, error) {
+	return r.rollItem(rnd, RollModifier{})
+}
+
+func (r *RollingTable) rollItem(rnd *rand.Rand, mod RollModifier) (Result, error) {
+	result := Result{Dice: r.dicestr}
+	if r.diceErr != nil {
+		return result, &DiceError{Dice: r.dicestr, Err: r.diceErr}
+	}
+	if len(r.intervals) == 0 {
+		return result, ErrEmptyTable
+	}
+	var err error
+	roll := mod.apply(func() int {
+		rolled, rollErr := r.dice.Roll(rnd)
+		if rollErr != nil {
+			err = rollErr
+		}
+		return rolled.Int()
+	})
+	if err != nil {
+		return result, &DiceError{Dice: r.dicestr, Err: err}
+	}
+	// A modified roll can go past the rows of the table
+	if !mod.IsZero() {
+		roll = clamp(roll, r.intervals[0].Min, r.intervals[len(r.intervals)-1].Max)
+	}
+	result.Roll = &roll
+	interval, found := r.interval(roll)
+	if !found {
+		return result, &NoRowError{Roll: roll}
+	}
+	result.Item = interval.item
+	result.Row = &interval.row
+	return result, nil
+}
+
+// item returns the item for a roll, or an empty string when no row has the roll
+func (r *RollingTable) item(roll int) string {
+	interval, _ := r.interval(roll)
+	return interval.item
+}
+
+// interval returns the interval that has the roll
+func (r *RollingTable) interval(roll int) (rollInterval, bool) {
+	i := sort.Search(len(r.intervals), func(i int) bool { return r.intervals[i].Max >= roll })
+	if i < len(r.intervals) && r.intervals[i].Min <= roll {
+		return r.intervals[i], true
+	}
+	return rollInterval{}, false
+}
+
+// AddItem adds an item for each of the given rolls. Rolls that follow on from each other
+// are added as a single range.
 func (r *RollingTable) AddItem(item string, pos ...int) {
-	for _, x := range pos {
-		if _, exists := r.items[x]; exists {
-			r.log.Warnf("Duplicate item: %s for roll %v", item, x)
+	for _, rolls := range newRangeSet(pos) {
+		r.AddRange(item, rolls.Min, rolls.Max)
+	}
+}
+
+// AddRange adds an item for every roll from lo to hi. The item replaces any earlier
+// items for the same rolls.
+func (r *RollingTable) AddRange(item string, lo, hi int) {
+	if lo > hi {
+		return
+	}
+	added := rollInterval{Range: Range{lo, hi}, item: item, row: len(r.rows)}
+	r.rows = append(r.rows, added)
+	intervals := []rollInterval{}
+	for _, existing := range r.intervals {
+		if existing.Max < lo || existing.Min > hi {
+			intervals = append(intervals, existing)
+			continue
+		}
+		overlap := Range{Min: max(existing.Min, lo), Max: min(existing.Max, hi)}
+		r.replaced = append(r.replaced, replacedRolls{Range: overlap, item: existing.item, by: item})
+		// Keep whatever is left of the existing interval on either side
+		if existing.Min < lo {
+			intervals = append(intervals, rollInterval{Range: Range{existing.Min, lo - 1}, item: existing.item, row: existing.row})
+		}
+		if existing.Max > hi {
+			intervals = append(intervals, rollInterval{Range: Range{hi + 1, existing.Max}, item: existing.item, row: existing.row})
 		}
-		r.items[x] = item
 	}
+	intervals = append(intervals, added)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Min < intervals[j].Min })
+	r.intervals = intervals
 }
 
+// AllItems returns each item that can be selected, in the order of their rolls
 func (r RollingTable) AllItems() []string {
 	values := []string{}
-	for _, val := range r.items {
-		values = append(values, val)
+	for _, interval := range r.intervals {
+		values = append(values, interval.item)
 	}
 	return util.DeDupe(values)
 }
 
+// covered returns every roll that selects an item
+func (r *RollingTable) covered() rangeSet {
+	ranges := make([]Range, len(r.intervals))
+	for i, interval := range r.intervals {
+		ranges[i] = interval.Range
+	}
+	return normalize(ranges)
+}
+
 // Validate that all numbers in the table are represented, that all numbers can be rolled, and there are no overlapping rolls
-func (r *RollingTable) Validate() {
-	count, sides, err := parseDiceString(r.dicestr)
+func (r *RollingTable) Validate() []Issue {
+	issues := []Issue{}
+	for _, replaced := range r.replaced {
+		issues = append(issues, newIssue(SeverityWarning, IssueDuplicateRow, "%q for roll %v is replaced by %q", replaced.item, replaced.Range, replaced.by))
+	}
+	if r.diceErr != nil {
+		return append(issues, newIssue(SeverityError, IssueInvalidDice, "%v", r.diceErr))
+	}
+	outcomes, err := r.dice.Outcomes()
 	if err != nil {
-		r.log.Warn(err)
+		return append(issues, newIssue(SeverityInfo, IssueUnchecked, "unable to check rolls for %s: %v", r.dicestr, err))
 	}
-	minRoll := count
-	maxRoll := count * sides
-	keys := []int{}
+	rollable := rangeSet(outcomes)
+	covered := r.covered()
 
 	//look for rolls that can't be reached
-	for k := range r.items {
-		if k < minRoll || k > maxRoll {
-			r.log.Warnf("%v is outside of the dice range", k)
-		}
-		keys = append(keys, k)
-	}
-
-	// Determine all possible numbers that can be rolled
-	var allRolls = make([]int, maxRoll-minRoll+1)
-	for i, x := 0, minRoll; x <= maxRoll; i, x = i+1, x+1 {
-		allRolls[i] = x
+	for _, rolls := range covered.subtract(rollable) {
+		issues = append(issues, newIssue(SeverityWarning, IssueUnreachable, "%v is outside of the dice range", rolls))
 	}
 
 	// Look for rolls that can't be made. Table is missing numbers
-	diff := util.Difference(allRolls, keys)
-	for _, roll := range diff {
-		r.log.Warnf("%v is not rollable", roll)
+	for _, rolls := range rollable.subtract(covered) {
+		issues = append(issues, newIssue(SeverityWarning, IssueGap, "%v is not rollable", rolls))
 	}
+	return issues
 }
 
-func (r RollingTable) GetTable(t *tablewriter.Table, name string) *tablewriter.Table {
-	for roll, item := range r.items {
-		t.Append([]string{item, strconv.Itoa(roll)})
+// Describe lists the rows in the order they were added with the rolls given for each
+func (r RollingTable) Describe() Description {
+	rows := make([]RowDescription, len(r.rows))
+	for i, row := range r.rows {
+		rolls := row.Range
+		rows[i] = RowDescription{Item: row.item, Range: &rolls, Source: r.rowSource(i)}
 	}
-	t.SetHeader([]string{name, r.dicestr})
-	return t
+	return Description{Kind: KindDice, Dice: r.dicestr, Rows: rows}
 }
 
-func NewRollingTable(d string) RollingTable {
-	table := RollingTable{
-		items:   map[int]string{},
-		dicestr: d,
-		log:     *log.NewEntry(log.StandardLogger()),
-	}
-	
-	return table
-}
-
-func (r RollingTable) WithLogger(logger *log.Entry) RollingTable {
-	r.log = *logger
-	return r
+func (r RollingTable) rowCount() int {
+	return len(r.rows)
 }
 
-func parseDiceString(dicestr string) (int, int, error) {
-	pattern := dice.StdRoller{}.Pattern()
-	matches := pattern.FindStringSubmatch(dicestr)
-
-	count, err := strconv.ParseInt(matches[1], 10, 0)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	sides, err := strconv.ParseInt(matches[2], 10, 0)
-	if err != nil {
-		return 0, 0, err
+func NewRollingTable(d string) RollingTable {
+	parsed, err := ParseDice(d)
+	table := RollingTable{
+		rows:      []rollInterval{},
+		intervals: []rollInterval{},
+		dicestr:   d,
+		dice:      parsed,
+		diceErr:   err,
 	}
 
-	return int(count), int(sides), nil
+	return table
 }
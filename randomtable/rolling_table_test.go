@@ -1,6 +1,8 @@
 package randomtable
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -8,36 +10,99 @@ func TestRollingTable(t *testing.T) {
 	r := NewRollingTable("2d4")
 
 	r.AddItem("Hello", 2, 3, 4, 5, 6, 7, 8)
-	if r.GetItem() != "Hello" {
-		t.Error("Didn't get expected item")
+	result, err := r.GetItem(NewRand(1))
+	if err != nil || result.Item != "Hello" {
+		t.Errorf("Didn't get expected item: %v", err)
+	}
+	if result.Dice != "2d4" || result.Roll == nil || *result.Roll < 2 || *result.Roll > 8 || result.Row == nil || *result.Row != 0 {
+		t.Errorf("Expected the roll and row of the item but got %+v", result)
 	}
 }
 
-type DiceResult struct {
-	count int
-	sides int
-	err   error
-}
-type ParseDiceStringTest struct {
-	dicestr  string
-	expected DiceResult
+func TestRollingTableErrors(t *testing.T) {
+	gap := NewRollingTable("1d2")
+	gap.AddItem("One", 1)
+	var noRow *NoRowError
+	for x := 0; x < 20; x++ {
+		if _, err := gap.GetItem(NewRand(int64(x))); err != nil && !errors.As(err, &noRow) {
+			t.Errorf("Expected a missing row but got %v", err)
+		}
+	}
+	if noRow == nil || noRow.Roll != 2 {
+		t.Errorf("Expected no row for a roll of 2 but got %v", noRow)
+	}
+
+	bad := NewRollingTable("lots")
+	bad.AddItem("Never", 1)
+	var diceErr *DiceError
+	if _, err := bad.GetItem(NewRand(1)); !errors.As(err, &diceErr) || diceErr.Dice != "lots" {
+		t.Errorf("Expected a dice error but got %v", err)
+	}
+
+	empty := NewRollingTable("1d6")
+	if _, err := empty.GetItem(NewRand(1)); !errors.Is(err, ErrEmptyTable) {
+		t.Errorf("Expected an empty table but got %v", err)
+	}
 }
 
-func TestParseDiceString(t *testing.T) {
-	cases := []ParseDiceStringTest{
-		{
-			dicestr:  "1d6",
-			expected: DiceResult{count: 1, sides: 6, err: nil},
-		},
-		{
-			dicestr:  "2d10",
-			expected: DiceResult{count: 2, sides: 10, err: nil},
-		},
-	}
-	for _, c := range cases {
-		actualCount, actualSides, err := parseDiceString(c.dicestr)
-		if actualCount != c.expected.count || actualSides != c.expected.sides || err != c.expected.err {
-			t.Errorf("Expected: %v, %v, %v but got %v, %v, %v", c.expected.count, c.expected.sides, c.expected.err, actualCount, actualSides, err)
+func TestRollingTableIntervals(t *testing.T) {
+	r := NewRollingTable("1d10000")
+	r.AddRange("Low", 1, 5000)
+	r.AddRange("High", 5001, 10000)
+	r.AddRange("Middle", 4000, 6000)
+	r.AddItem("Ends", 1, 10000)
+
+	expected := map[int]string{1: "Ends", 2: "Low", 3999: "Low", 4000: "Middle", 6000: "Middle", 6001: "High", 9999: "High", 10000: "Ends", 0: "", 10001: ""}
+	for roll, item := range expected {
+		if got := r.item(roll); got != item {
+			t.Errorf("Expected %q for %d but got %q", item, roll, got)
 		}
 	}
+	if items := r.AllItems(); !reflect.DeepEqual(items, []string{"Ends", "Low", "Middle", "High"}) {
+		t.Errorf("Expected the items in the order of their rolls but got %v", items)
+	}
+	if len(r.intervals) != 5 {
+		t.Errorf("Expected 5 intervals but got %v", r.intervals)
+	}
+
+	messages := []string{}
+	for _, issue := range r.Validate() {
+		messages = append(messages, issue.Message)
+	}
+	expectedMessages := []string{
+		`"Low" for roll 4000-5000 is replaced by "Middle"`,
+		`"High" for roll 5001-6000 is replaced by "Middle"`,
+		`"Low" for roll 1 is replaced by "Ends"`,
+		`"High" for roll 10000 is replaced by "Ends"`,
+	}
+	if !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("Expected issues %v but got %v", expectedMessages, messages)
+	}
+
+	desc := r.Describe()
+	rows := []string{}
+	for _, row := range desc.Rows {
+		rows = append(rows, row.Item+" "+row.Range.String())
+	}
+	expectedRows := []string{"Low 1-5000", "High 5001-10000", "Middle 4000-6000", "Ends 1", "Ends 10000"}
+	if desc.Kind != KindDice || desc.Dice != "1d10000" || !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("Expected the rows as they were added %v but got %s %s %v", expectedRows, desc.Kind, desc.Dice, rows)
+	}
+}
+
+func TestRollingTableGaps(t *testing.T) {
+	r := NewRollingTable("3d100")
+	r.AddRange("Low", 3, 100)
+	r.AddRange("High", 150, 320)
+	messages := []string{}
+	for _, issue := range r.Validate() {
+		messages = append(messages, string(issue.Kind)+": "+issue.Message)
+	}
+	expected := []string{
+		"unreachable: 301-320 is outside of the dice range",
+		"gap: 101-149 is not rollable",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected issues %v but got %v", expected, messages)
+	}
 }
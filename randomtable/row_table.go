@@ -0,0 +1,55 @@
+package randomtable
+
+import (
+	"strconv"
+)
+
+// Row is a single row of a markdown table keyed by the header of each column
+type Row map[string]string
+
+// RowTable keeps the rows of a markdown table together so that one roll can select every
+// column of the same row. The selector is a table of the same kind as the columns whose
+// items are the index of each row.
+type RowTable struct {
+	headers  []string
+	tables   []string
+	rows     [][]string
+	selector Table
+}
+
+// AddRow adds the cells of a row, in the same order as the headers, and returns the index of the row
+func (r *RowTable) AddRow(cells []string) int {
+	r.rows = append(r.rows, cells)
+	return len(r.rows) - 1
+}
+
+// Headers returns the headers of each column
+func (r RowTable) Headers() []string {
+	return r.headers
+}
+
+// Selector returns the table used to pick the index of a row
+func (r RowTable) Selector() Table {
+	return r.selector
+}
+
+// getRow returns the cells for the row at the given index. The index is a string as
+// it comes from an item on the selector table.
+func (r RowTable) getRow(index string) ([]string, bool) {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(r.rows) {
+		return nil, false
+	}
+	return r.rows[i], true
+}
+
+// NewRowTable creates a RowTable for columns with the given headers. tables are the
+// names of the table created for each column and are used when rendering a cell.
+func NewRowTable(headers, tables []string, selector Table) RowTable {
+	return RowTable{
+		headers:  headers,
+		tables:   tables,
+		rows:     [][]string{},
+		selector: selector,
+	}
+}
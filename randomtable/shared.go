@@ -0,0 +1,81 @@
+package randomtable
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SharedTree holds a tree that is read by many goroutines while a newer tree can be
+// swapped in at any time. Trees must not have tables added once they are stored.
+type SharedTree struct {
+	current atomic.Pointer[snapshot]
+	// guards Store and Reject so that versions aren't lost
+	mu sync.Mutex
+}
+
+type snapshot struct {
+	tree   Tree
+	status Status
+}
+
+// Status describes the tree that is being served and the last reload that was rejected
+type Status struct {
+	// Version counts the trees that have been stored, starting at 1
+	Version  int       `json:"version"`
+	Hash     string    `json:"hash"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// FailedReload is set when a reload was rejected after the current tree was stored
+	FailedReload *FailedReload `json:"failedReload,omitempty"`
+}
+
+// FailedReload holds why a newer tree wasn't stored. Error is set when the tables couldn't
+// be loaded and Issues holds the errors found when they were validated.
+type FailedReload struct {
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
+	Issues []Issue   `json:"issues,omitempty"`
+}
+
+// NewSharedTree returns a SharedTree holding the given tree
+func NewSharedTree(tree Tree) *SharedTree {
+	s := &SharedTree{}
+	s.Store(tree)
+	return s
+}
+
+// Load returns the current tree. Changes made with the With methods only affect the
+// returned copy so each caller can pick its own formatter or seed.
+func (s *SharedTree) Load() Tree {
+	return s.current.Load().tree
+}
+
+// Store replaces the current tree. Callers that already loaded the old tree keep using it.
+func (s *SharedTree) Store(tree Tree) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{Version: 1, Hash: tree.Hash(), LoadedAt: time.Now()}
+	if old := s.current.Load(); old != nil {
+		status.Version = old.status.Version + 1
+	}
+	s.current.Store(&snapshot{tree: tree, status: status})
+}
+
+// Reject records a reload that failed. The current tree is kept.
+func (s *SharedTree) Reject(err error, issues []Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.current.Load()
+	failed := &FailedReload{At: time.Now(), Issues: issues}
+	if err != nil {
+		failed.Error = err.Error()
+	}
+	status := old.status
+	status.FailedReload = failed
+	s.current.Store(&snapshot{tree: old.tree, status: status})
+}
+
+// Status returns the status of the current tree
+func (s *SharedTree) Status() Status {
+	return s.current.Load().status
+}
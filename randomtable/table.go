@@ -2,57 +2,101 @@ package randomtable
 
 import (
 	"math/rand"
-	"time"
 
-	"github.com/olekukonko/tablewriter"
+	"github.com/awwithro/makemea/util"
 )
 
+// Table is a list of items that can be selected at random. GetItem uses the given
+// source of randomness, or the default source from math/rand when it is nil. An error is
+// returned when no item can be picked, such as from a table without any rows.
 type Table interface {
-	GetItem() string
+	GetItem(*rand.Rand) (Result, error)
 	AddItem(string, ...int)
-	Validate()
+	Validate() []Issue
 	AllItems() []string
-	GetTable(*tablewriter.Table, string) *tablewriter.Table
+	// Describe returns the kind of the table and its rows
+	Describe() Description
+}
+
+// Result is an item that was picked from a table along with how it was picked
+type Result struct {
+	Item string `json:"item"`
+	// Dice are the dice that were rolled to pick the item, if any
+	Dice string `json:"dice,omitempty"`
+	// Roll is the total of the dice with any modifier
+	Roll *int `json:"roll,omitempty"`
+	// Row is the index of the row that was picked, in the order the rows were added
+	Row *int `json:"row,omitempty"`
+}
+
+// modifiableTable is a table whose rolls can be changed by a roll modifier
+type modifiableTable interface {
+	rollItem(r *rand.Rand, mod RollModifier) (Result, error)
 }
 
 type RandomTable struct {
 	items []string
-	seed  int
-	rand *rand.Rand
+	rowSources
 }
 
-func (r *RandomTable) GetItem() string {
-	randomIndex := r.rand.Intn(len(r.items))
-	return r.items[randomIndex]
+func (r *RandomTable) GetItem(rnd *rand.Rand) (Result, error) {
+	return r.rollItem(rnd, RollModifier{})
+}
+
+func (r *RandomTable) rollItem(rnd *rand.Rand, mod RollModifier) (Result, error) {
+	if len(r.items) == 0 {
+		return Result{}, ErrEmptyTable
+	}
+	randomIndex := mod.apply(func() int { return diceRand{rnd}.Intn(len(r.items)) })
+	randomIndex = clamp(randomIndex, 0, len(r.items)-1)
+	return Result{Item: r.items[randomIndex], Row: &randomIndex}, nil
 }
 
 func (r *RandomTable) AddItem(item string, n ...int) {
 	r.items = append(r.items, item)
 }
 
-func (r *RandomTable) Validate() {
-	return
+// Validate reports items that are listed more than once
+func (r *RandomTable) Validate() []Issue {
+	return duplicateItems(r.items)
+}
+
+// duplicateItems reports each item that is listed more than once. This is often done on
+// purpose to make an item more likely so it is only for information.
+func duplicateItems(items []string) []Issue {
+	issues := []Issue{}
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[item]++
+	}
+	for _, item := range util.DeDupe(items) {
+		if counts[item] > 1 {
+			issues = append(issues, newIssue(SeverityInfo, IssueDuplicateRow, "%q is listed %d times", item, counts[item]))
+		}
+	}
+	return issues
 }
 
 func (r RandomTable) AllItems() []string {
 	return r.items
 }
 
-func (r RandomTable) GetTable(t *tablewriter.Table, name string) *tablewriter.Table {
-	for _, item := range r.items {
-		t.Append([]string{item})
+func (r RandomTable) Describe() Description {
+	rows := make([]RowDescription, len(r.items))
+	for i, item := range r.items {
+		rows[i] = RowDescription{Item: item, Source: r.rowSource(i)}
 	}
-	t.SetHeader([]string{name})
-	return t
+	return Description{Kind: KindRandom, Rows: rows}
+}
+
+func (r RandomTable) rowCount() int {
+	return len(r.items)
 }
 
 func NewRandomTable() RandomTable {
-	seed := time.Now().Nanosecond()
 	t := RandomTable{
 		items: []string{},
-		seed:  seed,
-		rand: rand.New(rand.NewSource(int64(seed))),
 	}
-	
+
 	return t
 }
@@ -1,12 +1,18 @@
 package randomtable
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestRandomTable(t *testing.T) {
 	r := NewRandomTable()
+	if _, err := r.GetItem(NewRand(1)); !errors.Is(err, ErrEmptyTable) {
+		t.Errorf("Expected an empty table but got %v", err)
+	}
 	r.AddItem("Hello")
-	if r.GetItem() != "Hello" {
-		t.Error("Didn't get expected item")
+	if result, err := r.GetItem(NewRand(1)); err != nil || result.Item != "Hello" || *result.Row != 0 {
+		t.Errorf("Didn't get expected item: %+v %v", result, err)
 	}
 	all := r.AllItems()
 	if all[0] != "Hello" || len(all) != 1 {
@@ -0,0 +1,136 @@
+package randomtable
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+)
+
+// templateFuncNames has every function that templates can use. The functions from the tree
+// are placeholders that are replaced with functions bound to a generation when a template
+// is rendered, so they are never called.
+var templateFuncNames template.FuncMap
+
+// treeFuncTypes has the signature of each function that the tree adds to templates
+var treeFuncTypes = map[string]reflect.Type{}
+
+func init() {
+	templateFuncNames = sprig.FuncMap()
+	for name, f := range (&Tree{}).treeFuncs(nil, "") {
+		templateFuncNames[name] = f
+		treeFuncTypes[name] = reflect.TypeOf(f)
+	}
+}
+
+// templateCache holds the templates in items once they have been parsed, keyed by the text
+// of the item, so an item is only parsed once however often it is rendered.
+type templateCache struct {
+	templates sync.Map
+	// funcs are the functions templates may use, or nil for every function
+	funcs FuncSet
+}
+
+// cachedTemplate is a parsed item. The parsed template is never executed. Items are rendered
+// with copies of it whose functions call the functions for the generation they're bound to.
+// Copies are kept once they have been used as making a copy means copying every function.
+type cachedTemplate struct {
+	parsed *template.Template
+	err    error
+	bound  sync.Pool
+	// funcs are the functions the template was parsed with, or nil for every function
+	funcs FuncSet
+}
+
+// boundTemplate is a copy of a parsed template that one generation can render at a time
+type boundTemplate struct {
+	tmpl  *template.Template
+	funcs template.FuncMap
+	// ctx is checked before every function is called, so a template that's busy calling
+	// functions stops once the generation is done
+	ctx context.Context
+}
+
+func newTemplateCache(funcs FuncSet) *templateCache {
+	return &templateCache{funcs: funcs}
+}
+
+// get returns the template for an item, parsing it the first time it's seen
+func (c *templateCache) get(item string) *cachedTemplate {
+	if c == nil {
+		return parseTemplate(item, nil)
+	}
+	if cached, ok := c.templates.Load(item); ok {
+		return cached.(*cachedTemplate)
+	}
+	cached, _ := c.templates.LoadOrStore(item, parseTemplate(item, c.funcs))
+	return cached.(*cachedTemplate)
+}
+
+// parseTemplate parses an item with only the functions in funcs, so it fails if any
+// template it defines uses a function that isn't in funcs
+func parseTemplate(item string, funcs FuncSet) *cachedTemplate {
+	tmpl, err := template.New("item").Funcs(funcs.funcMap()).Parse(item)
+	if err != nil && funcs != nil {
+		// Parse again with every function to name the function that isn't allowed
+		if all, allErr := template.New("item").Funcs(templateFuncNames).Parse(item); allErr == nil {
+			if checkErr := funcs.check(all); checkErr != nil {
+				err = checkErr
+			}
+		}
+	}
+	return &cachedTemplate{parsed: tmpl, err: err, funcs: funcs}
+}
+
+// bind returns a copy of the template whose functions are bound to the given ones and stop
+// once ctx is done. It must be released once it has been executed.
+func (c *cachedTemplate) bind(ctx context.Context, funcs template.FuncMap) (*boundTemplate, error) {
+	b, _ := c.bound.Get().(*boundTemplate)
+	if b == nil {
+		tmpl, err := c.parsed.Clone()
+		if err != nil {
+			return nil, err
+		}
+		b = &boundTemplate{}
+		b.tmpl = tmpl.Funcs(b.dispatchers(c.funcs))
+	}
+	b.funcs = funcs
+	b.ctx = ctx
+	return b, nil
+}
+
+func (c *cachedTemplate) release(b *boundTemplate) {
+	b.funcs = nil
+	b.ctx = nil
+	c.bound.Put(b)
+}
+
+// dispatchers returns functions that check the context the template is bound to and then
+// call the function with the same name, using the one that is bound to the template for
+// the tree's functions. Only the functions in allowed are dispatched, or every function
+// when it's nil. A function whose context is done panics with the context's error, which
+// the template returns as the error from calling it.
+func (b *boundTemplate) dispatchers(allowed FuncSet) template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, fn := range allowed.funcMap() {
+		name, typ := name, reflect.TypeOf(fn)
+		f := reflect.ValueOf(fn)
+		_, isTreeFunc := treeFuncTypes[name]
+		funcs[name] = reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+			if err := b.ctx.Err(); err != nil {
+				panic(err)
+			}
+			call := f
+			if isTreeFunc {
+				call = reflect.ValueOf(b.funcs[name])
+			}
+			if typ.IsVariadic() {
+				return call.CallSlice(args)
+			}
+			return call.Call(args)
+		}).Interface()
+	}
+	return funcs
+}
@@ -0,0 +1,49 @@
+package randomtable
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// again renders the same item inside itself until the roll is a 1
+const templateCacheTest = `
+| Again                                                                       |
+| --------------------------------------------------------------------------- |
+| {{ if eq (roll "1d3") "1" }}done{{ else }}again {{ lookup "again" }}{{ end }} |
+`
+
+func TestTemplateCache(t *testing.T) {
+	tree := NewTree().WithSeed(3)
+	md := NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(templateCacheTest), &buf); err != nil {
+		t.Fatal(err)
+	}
+	item := tree.tables.Get("again").(TableNode).AllItems()[0]
+	first, err := tree.parseItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := tree.parseItem(item); second != first {
+		t.Error("Expected the item to only be parsed once")
+	}
+	nested := false
+	for x := 0; x < 20; x++ {
+		result, err := tree.GetItem(context.Background(), "again")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(result, "done") {
+			t.Errorf("Expected every result to end with done but got %q", result)
+		}
+		nested = nested || strings.Count(result, "again") > 1
+	}
+	if !nested {
+		t.Error("Expected the item to be rendered inside itself")
+	}
+	if _, err := tree.parseItem("{{ lookup }"); err == nil {
+		t.Error("Expected an error parsing an unclosed action")
+	}
+}
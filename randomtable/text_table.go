@@ -1,15 +1,14 @@
 package randomtable
 
-import (
-	"github.com/olekukonko/tablewriter"
-)
+import "math/rand"
 
 type TextTable struct {
 	text string
+	rowSources
 }
 
-func (t *TextTable) GetItem() string {
-	return t.text
+func (t *TextTable) GetItem(r *rand.Rand) (Result, error) {
+	return Result{Item: t.text}, nil
 }
 
 func (t *TextTable) AddItem(item string, n ...int) {
@@ -20,14 +19,20 @@ func (t TextTable) AllItems() []string {
 	return []string{t.text}
 }
 
-func (t TextTable) Validate() {
+func (t TextTable) Validate() []Issue {
+	return nil
+}
 
+// Describe gives the text as a single row
+func (t TextTable) Describe() Description {
+	return Description{Kind: KindText, Rows: []RowDescription{{Item: t.text, Source: t.rowSource(0)}}}
 }
 
-func (t TextTable) GetTable(tb *tablewriter.Table, name string) *tablewriter.Table {
-	tb.Append([]string{t.text})
-	tb.SetHeader([]string{t.text})
-	return tb
+func (t TextTable) rowCount() int {
+	if t.text == "" {
+		return 0
+	}
+	return 1
 }
 
 func NewTextTable() TextTable {
@@ -0,0 +1,179 @@
+package randomtable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Trace records a single step taken while generating an item, such as a lookup on a table
+// or a roll of the dice. Steps taken while rendering the result are kept as children.
+type Trace struct {
+	Function string `json:"function"`
+	Table    string `json:"table,omitempty"`
+	// Name is the name a value was remembered or recalled by
+	Name     string `json:"name,omitempty"`
+	Dice     string `json:"dice,omitempty"`
+	Modifier string `json:"modifier,omitempty"`
+	// Args are the arguments that the item was rendered with
+	Args     map[string]interface{} `json:"args,omitempty"`
+	Roll     *int                   `json:"roll,omitempty"`
+	Row      *int                   `json:"row,omitempty"`
+	Item     string                 `json:"item,omitempty"`
+	Result   string                 `json:"result"`
+	Children []*Trace               `json:"children,omitempty"`
+	parent   *Trace
+}
+
+// String prints the trace and its children as an indented tree
+func (t *Trace) String() string {
+	b := &strings.Builder{}
+	t.write(b, 0)
+	return b.String()
+}
+
+func (t *Trace) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(t.Function)
+	if t.Table != "" {
+		b.WriteString(" " + t.Table)
+	}
+	if t.Name != "" {
+		b.WriteString(" " + t.Name)
+	}
+	details := []string{}
+	if t.Dice != "" {
+		details = append(details, "dice: "+t.Dice)
+	}
+	if len(t.Args) > 0 {
+		args := []string{}
+		for k, v := range t.Args {
+			args = append(args, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(args)
+		details = append(details, "args: "+strings.Join(args, " "))
+	}
+	if t.Modifier != "" {
+		details = append(details, "modifier: "+t.Modifier)
+	}
+	if t.Roll != nil {
+		details = append(details, fmt.Sprintf("roll: %d", *t.Roll))
+	}
+	if t.Row != nil {
+		details = append(details, fmt.Sprintf("row: %d", *t.Row+1))
+	}
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	fmt.Fprintf(b, " => %q\n", t.Result)
+	for _, child := range t.Children {
+		child.write(b, depth+1)
+	}
+}
+
+// setArgs records the arguments an item is rendered with
+func (t *Trace) setArgs(args map[string]interface{}) {
+	if len(args) > 0 {
+		t.Args = args
+	}
+}
+
+// generation holds the state of a single call to GetItem as templates are rendered
+type generation struct {
+	root    *Trace
+	current *Trace
+	// depth is the number of lookups that are currently nested
+	depth int
+	// remembered holds the values kept with remember by their names
+	remembered map[string]interface{}
+	// sticky holds the result of each sticky lookup by its table and arguments
+	sticky map[string]string
+	// ctx stops the generation when it's done and holds its limits
+	ctx    context.Context
+	limits Limits
+	// lookups, dice and ranged count what has been done towards the limits
+	lookups int
+	dice    int
+	ranged  int
+	// formatter formats the items of this generation, see Tree.generationFormatter
+	formatter Formatter
+}
+
+func newGeneration(ctx context.Context) *generation {
+	return &generation{
+		remembered: map[string]interface{}{},
+		sticky:     map[string]string{},
+		ctx:        ctx,
+		limits:     LimitsFromContext(ctx),
+	}
+}
+
+// push starts a new step as a child of the current step
+func (g *generation) push(step *Trace) *Trace {
+	if g.current == nil {
+		g.root = step
+	} else {
+		g.current.Children = append(g.current.Children, step)
+	}
+	step.parent = g.current
+	g.current = step
+	g.depth++
+	return step
+}
+
+// pop finishes the current step
+func (g *generation) pop() {
+	if g.current != nil {
+		g.current = g.current.parent
+		g.depth--
+	}
+}
+
+// enter starts a step that looks up a table. An error is returned when lookups are
+// nested more than max deep, when there have been too many lookups or when the generation
+// is done. The step must be popped either way.
+func (g *generation) enter(step *Trace, max int) (*Trace, error) {
+	g.push(step)
+	if g.depth > max {
+		return step, &LookupDepthError{Depth: max, Chain: g.chain()}
+	}
+	if err := g.ctx.Err(); err != nil {
+		return step, err
+	}
+	g.lookups++
+	if g.limits.MaxLookups > 0 && g.lookups > g.limits.MaxLookups {
+		return step, &LimitError{Limit: "lookups", Max: g.limits.MaxLookups}
+	}
+	return step, nil
+}
+
+// rollDice counts dice that are about to be rolled, returning an error when there would
+// be too many
+func (g *generation) rollDice(count int) error {
+	if err := g.ctx.Err(); err != nil {
+		return err
+	}
+	g.dice += count
+	if g.limits.MaxDice > 0 && g.dice > g.limits.MaxDice {
+		return &LimitError{Limit: "dice", Max: g.limits.MaxDice}
+	}
+	return nil
+}
+
+// chain returns the tables of every step from the root to the current step
+func (g *generation) chain() []string {
+	chain := []string{}
+	for step := g.current; step != nil; step = step.parent {
+		if step.Table != "" {
+			chain = append([]string{step.Table}, chain...)
+		}
+	}
+	return chain
+}
+
+// record adds a step that doesn't render anything else
+func (g *generation) record(step *Trace) {
+	g.push(step)
+	g.pop()
+}
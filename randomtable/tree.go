@@ -1,7 +1,8 @@
 package randomtable
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sort"
@@ -9,29 +10,70 @@ import (
 	"strings"
 	"text/template"
 
-	"github.com/Masterminds/sprig"
+	"github.com/awwithro/makemea/util"
 	"github.com/dghubble/trie"
-	"github.com/justinian/dice"
 	log "github.com/sirupsen/logrus"
 )
 
 // Tree holds lookup tables and allows for retrieveing tables as well as items from tables.
-// In addition, the tree handles the rendering of any templates that are a part of a table
+// In addition, the tree handles the rendering of any templates that are a part of a table.
+// Once all the tables have been added, a Tree is safe for concurrent use.
 type Tree struct {
 	tables         *trie.PathTrie
 	maxLookupDepth int
 	formatter      Formatter
+	decks          *deckStore
+	rand           *rand.Rand
+	// file is the markdown file that tables are being added from
+	file string
+	// loadIssues are problems found while tables were added, such as duplicate names
+	loadIssues *[]Issue
+	// duplicates decides what happens when a table is added with a name already in the tree
+	duplicates DuplicatePolicy
+	// templates holds the templates in items that have been parsed
+	templates *templateCache
+	// values can be used by every template that is rendered, such as .region
+	values map[string]interface{}
 }
 
 // TableNode embeds the table that was created and adds meta-data for use in the tree
 type TableNode struct {
 	Table
 	Hidden bool
+	// Source is where the table was found
+	Source Source
+	// Row is shared by all the tables created from the columns of a single markdown table
+	Row *RowTable
 }
 
 // A link to another table
 type LinkNode struct {
 	Link string
+	// Args are passed to the table when it is reached through the link, unless the
+	// lookup gives them itself
+	Args map[string]string
+	// Source is where the link was found
+	Source Source
+}
+
+// Source is the markdown file and lines that a table was found on. Lines start at 1
+// and are 0 when they aren't known.
+type Source struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	EndLine int    `json:"endLine,omitempty"`
+}
+
+// String formats the source as file:line
+func (s Source) String() string {
+	file := s.File
+	if file == "" {
+		file = "(unknown file)"
+	}
+	if s.Line > 0 {
+		return fmt.Sprintf("%s:%d", file, s.Line)
+	}
+	return file
 }
 
 func NewTree() Tree {
@@ -39,57 +81,119 @@ func NewTree() Tree {
 		tables:         trie.NewPathTrie(),
 		maxLookupDepth: 100,
 		formatter:      StringFormatter{},
+		decks:          newDeckStore(),
+		rand:           newTimeRand(),
+		loadIssues:     &[]Issue{},
+		templates:      newTemplateCache(nil),
 	}
 }
 
-// AddTable adds the given table with the given name.
-// Names have spaces removed and turned to lowercase
-func (t *Tree) AddTable(name string, table Table, hidden bool) {
-	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+// WithSeed returns a tree that uses a source of randomness with the given seed. The same seed
+// with the same tables will always give the same results. Decks start out fresh.
+func (t Tree) WithSeed(seed int64) Tree {
+	return t.WithRand(NewRand(seed))
+}
 
-	// check for existing table
-	_, err := t.GetItem(name)
-	// no err means we got a table
-	if err == nil {
-		log.WithField("table", name).Warn("Duplicate table entered")
+// WithRand returns a tree that uses the given source of randomness with fresh decks
+func (t Tree) WithRand(r *rand.Rand) Tree {
+	t.rand = r
+	t.decks = newDeckStore()
+	return t
+}
+
+// WithFile returns a tree that shares tables with this one and records that any tables
+// added to it were found in the given file
+func (t Tree) WithFile(file string) Tree {
+	t.file = file
+	return t
+}
+
+// WithValues returns a tree that shares tables with this one and gives the values to every
+// template it renders, however deeply the template is nested. A template uses a value the
+// same way as an argument, so .region for a value named region. Arguments given to a table
+// by a lookup or a link replace values with the same name.
+func (t Tree) WithValues(values map[string]string) Tree {
+	merged := make(map[string]interface{}, len(t.values)+len(values))
+	for k, v := range t.values {
+		merged[k] = v
 	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	t.values = merged
+	return t
+}
 
-	t.tables.Put(name, TableNode{Table: table, Hidden: hidden})
+// WithNewSession returns a tree that shares tables with this one but has its own
+// deck and repeat history state
+func (t Tree) WithNewSession() Tree {
+	t.decks = newDeckStore()
+	return t
 }
 
-// AddLink adds a reference to another table
-func (t *Tree) AddLink(name, table string) {
-	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+// AddTable adds the given table with the given name.
+// Names have spaces removed and turned to lowercase. It returns the table that items should
+// be added to, which is the table already in the tree when the duplicate policy merges rows,
+// and an error when the policy doesn't allow the table.
+func (t *Tree) AddTable(name string, table Table, hidden bool) (Table, error) {
+	return t.addTable(name, table, hidden, Source{File: t.file})
+}
 
-	// check for existing table
-	_, err := t.GetItem(name)
-	// no err means we got a table
-	if err == nil {
-		log.WithField("table", name).Warn("Duplicate table entered")
+// addRowTable attaches the rows of a markdown table to the table created for one of its
+// columns. Nothing is attached when the duplicate policy kept a different table.
+func (t *Tree) addRowTable(name string, row *RowTable, table Table) {
+	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+	tb, ok := t.tables.Get(name).(TableNode)
+	if !ok || tb.Table != table {
+		return
 	}
+	tb.Row = row
+	t.tables.Put(name, tb)
+}
 
-	t.tables.Put(name, LinkNode{Link: table})
+// AddLink adds a reference to another table. An error is returned when the link can't be
+// parsed or the duplicate policy doesn't allow it.
+func (t *Tree) AddLink(name, table string) error {
+	return t.addLink(name, table, Source{File: t.file})
 }
 
-// GetTable returns the table with the given name in the tree
+// GetTable returns the table with the given name in the tree. Links are followed to the
+// table they point to.
 func (t *Tree) GetTable(name string) (TableNode, string, error) {
-	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
-	table := t.tables.Get(name)
-	if table == nil {
-		return TableNode{},"", fmt.Errorf("%s table not found", name)
-	}
-	switch tb := table.(type) {
-	case TableNode:
-		switch tableTyped := tb.Table.(type) {
+	tb, name, _, err := t.getTable(name)
+	return tb, name, err
+}
+
+// getTable returns the table with the given name along with the arguments given by any links
+// that were followed to it. Links closer to the name take precedence.
+func (t *Tree) getTable(name string) (TableNode, string, map[string]interface{}, error) {
+	chain := []string{}
+	args := map[string]interface{}{}
+	for {
+		name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+		for _, link := range chain {
+			if link == name {
+				return TableNode{}, "", nil, fmt.Errorf("links lead back to %s: %s", name, strings.Join(append(chain, name), " -> "))
+			}
+		}
+		table := t.tables.Get(name)
+		if table == nil {
+			return TableNode{}, "", nil, &NotFoundError{Name: name}
+		}
+		switch tb := table.(type) {
+		case TableNode:
+			return tb, name, args, nil
+		case LinkNode:
+			chain = append(chain, name)
+			for k, v := range tb.Args {
+				if _, ok := args[k]; !ok {
+					args[k] = v
+				}
+			}
+			name = tb.Link
 		default:
-			tb.Table = tableTyped
-			return tb, name, nil
+			return TableNode{}, "", nil, fmt.Errorf("unknown Table Node: %v", tb)
 		}
-	case LinkNode:
-		linkedTable, name, err := t.GetTable(tb.Link)
-		return linkedTable, name, err
-	default:
-		return TableNode{}, "",fmt.Errorf("unknown Table Node: %v", tb)
 	}
 }
 
@@ -116,37 +220,223 @@ func (t *Tree) ListTables(prefix string, showHidden bool) []string {
 }
 
 // GetItem retrieves an item from a table and will render any items
-// that include templates.
-func (t *Tree) GetItem(table string) (string, error) {
-	tb, name, err := t.GetTable(table)
+// that include templates. Generating the item stops with an error when the context is
+// done or the item goes over the limits from WithLimits.
+func (t *Tree) GetItem(ctx context.Context, table string) (string, error) {
+	item, _, err := t.GetItemWithTrace(ctx, table)
+	return item, err
+}
+
+// GetItemWithTrace retrieves an item like GetItem and also returns a trace of every
+// lookup and roll that was made to generate it.
+func (t *Tree) GetItemWithTrace(ctx context.Context, table string) (string, *Trace, error) {
+	return t.GetItemWithModifier(ctx, table, RollModifier{})
+}
+
+// GetItemWithModifier retrieves an item like GetItemWithTrace with the roll on the table
+// changed by the modifier. Tables that are looked up while rendering the item are rolled
+// on as normal.
+func (t *Tree) GetItemWithModifier(ctx context.Context, table string, mod RollModifier) (string, *Trace, error) {
+	gen := newGeneration(ctx)
+	var item string
+	err := gen.run(func() error {
+		var err error
+		item, err = t.getItem(gen, "lookup", table, mod, nil)
+		formatter := t.generationFormatter(gen)
+		finishTrace(formatter, gen.root)
+		item = formatter.Finish(item)
+		return err
+	})
+	// The generation may still be running once the context is done, so nothing it set is used
+	if err != nil && ctx.Err() != nil {
+		return "", nil, err
+	}
+	return item, gen.root, err
+}
+
+// getItem selects and renders an item from the table, recording the step in the generation.
+// The item is rendered with the given arguments as its data.
+func (t *Tree) getItem(gen *generation, function, table string, mod RollModifier, args map[string]interface{}) (string, error) {
+	step, err := gen.enter(&Trace{Function: function, Table: table}, t.maxLookupDepth)
+	defer gen.pop()
+	if err != nil {
+		return "", err
+	}
+	tb, name, data, err := t.getTable(table)
 	if err != nil {
 		return "", err
 	}
-	item := tb.GetItem()
-	item = t.formatter.Format(item, name)
-	return t.renderItem(item, name)
+	step.Table = name
+	mergeArgs(data, args)
+	step.setArgs(data)
+	if !mod.IsZero() {
+		step.Modifier = mod.String()
+	}
+	var item string
+	// Decks are dealt from the tree's state rather than rolled
+	if _, isDeck := tb.Table.(*DeckTable); isDeck {
+		if !mod.IsZero() {
+			return "", &TableError{Table: name, Err: ErrCannotModify}
+		}
+		item, err = t.decks.draw(name, tb.Table, t.rand)
+		if err != nil {
+			return "", &TableError{Table: name, Err: err}
+		}
+	} else {
+		item, err = t.selectItem(gen, tb.Table, mod, step)
+		if err != nil {
+			return "", err
+		}
+	}
+	step.Item = item
+	step.Result, err = t.renderTableItem(gen, item, newElement(name, step), data)
+	return step.Result, err
+}
+
+// mergeArgs adds the arguments given to a lookup to the arguments from links. Arguments
+// without a value, such as one the calling table wasn't given, leave the link's value.
+func mergeArgs(data, args map[string]interface{}) {
+	for k, v := range args {
+		if v != nil {
+			data[k] = v
+		}
+	}
+}
+
+// selectItem picks an item from the table and records how it was picked on the step. The dice
+// rolled by the table count towards the generation's limit. An item that can't be picked is
+// returned as a TableError for the step's table.
+func (t *Tree) selectItem(gen *generation, table Table, mod RollModifier, step *Trace) (string, error) {
+	if rolling, ok := table.(*RollingTable); ok {
+		count := rolling.dice.Count()
+		if mod.Advantage != mod.Disadvantage {
+			count *= 2
+		}
+		if err := gen.rollDice(count); err != nil {
+			return "", err
+		}
+	}
+	var result Result
+	var err error
+	if modifiable, ok := table.(modifiableTable); ok {
+		result, err = modifiable.rollItem(t.rand, mod)
+	} else {
+		result, err = table.GetItem(t.rand)
+	}
+	step.Dice, step.Roll, step.Row = result.Dice, result.Roll, result.Row
+	if err != nil {
+		return "", &TableError{Table: step.Table, Err: err}
+	}
+	return result.Item, nil
+}
+
+// GetRow selects a single row from the markdown table that the named table is a column of.
+// Every cell in the row is rendered. The headers of the row are returned in column order.
+func (t *Tree) GetRow(ctx context.Context, table string) (Row, []string, error) {
+	gen := newGeneration(ctx)
+	var row Row
+	var columns []string
+	err := gen.run(func() error {
+		var err error
+		row, columns, err = t.getRow(gen, table, nil)
+		formatter := t.generationFormatter(gen)
+		for column, item := range row {
+			row[column] = formatter.Finish(item)
+		}
+		return err
+	})
+	// The generation may still be running once the context is done, so nothing it set is used
+	if err != nil && ctx.Err() != nil {
+		return nil, nil, err
+	}
+	return row, columns, err
+}
+
+func (t *Tree) getRow(gen *generation, table string, args map[string]interface{}) (Row, []string, error) {
+	step, err := gen.enter(&Trace{Function: "lookupRow", Table: table}, t.maxLookupDepth)
+	defer gen.pop()
+	if err != nil {
+		return nil, nil, err
+	}
+	tb, name, data, err := t.getTable(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	step.Table = name
+	mergeArgs(data, args)
+	step.setArgs(data)
+	if tb.Row == nil {
+		return nil, nil, &TableError{Table: name, Err: ErrNoRows}
+	}
+	var index string
+	if _, isDeck := tb.Row.selector.(*DeckTable); isDeck {
+		index, err = t.decks.draw(name+"#row", tb.Row.selector, t.rand)
+		if err != nil {
+			return nil, nil, &TableError{Table: name, Err: err}
+		}
+	} else {
+		index, err = t.selectItem(gen, tb.Row.selector, RollModifier{}, step)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	cells, found := tb.Row.getRow(index)
+	if !found {
+		return nil, nil, fmt.Errorf("no row found on %s", name)
+	}
+	if i, err := strconv.Atoi(index); err == nil {
+		step.Row = &i
+	}
+	row := Row{}
+	results := []string{}
+	for i, cell := range cells {
+		item, err := t.renderTableItem(gen, cell, newElement(tb.Row.tables[i], step), data)
+		if err != nil {
+			return nil, nil, err
+		}
+		row[tb.Row.headers[i]] = item
+		results = append(results, tb.Row.headers[i]+": "+item)
+	}
+	step.Result = strings.Join(results, ", ")
+	return row, tb.Row.Headers(), nil
+}
+
+// renderTableItem renders and formats an item that was selected from the element's table
+func (t *Tree) renderTableItem(gen *generation, item string, element Element, args map[string]interface{}) (string, error) {
+	item, err := t.renderItem(gen, item, element.Table, args)
+	if err != nil {
+		return "", err
+	}
+	return t.generationFormatter(gen).Format(item, element), nil
 }
 
 // renderItem will render any templates for a given item. Table is the path the item was
-// found on to allow for lookups using relative paths
-func (t *Tree) renderItem(item string, table string) (string, error) {
-	funcMap := template.FuncMap{
-		"lookup": t.getLookup(table),
-		"roll":   t.roll,
-		"fudge":  t.getFudge(table),
-		"pick": pickItem,
-		"chance": chance,
-	}
-	mergedFuncMaps := sprig.FuncMap()
-	for k, v := range funcMap {
-		mergedFuncMaps[k] = v
-	}
-	tmpl, err := template.New("item").Funcs(template.FuncMap(mergedFuncMaps)).Parse(item)
+// found on to allow for lookups using relative paths. The arguments are the data for the
+// templates, so a template can use .name for each of them.
+func (t *Tree) renderItem(gen *generation, item string, table string, args map[string]interface{}) (string, error) {
+	cached := t.templates.get(item)
+	if cached.err != nil {
+		return "", cached.err
+	}
+	bound, err := cached.bind(gen.ctx, t.treeFuncs(gen, table))
 	if err != nil {
 		return "", err
 	}
-	buf := &bytes.Buffer{}
-	err = tmpl.Execute(buf, nil)
+	defer cached.release(bound)
+	buf := &limitedWriter{gen: gen}
+	err = bound.tmpl.Execute(buf, t.templateData(args))
+	// Every nested template would wrap the error again so the chain is returned as it is
+	var depthErr *LookupDepthError
+	if errors.As(err, &depthErr) {
+		return "", depthErr
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return "", limitErr
+	}
+	if err != nil && gen.ctx.Err() != nil {
+		return "", gen.ctx.Err()
+	}
 	if err != nil {
 		return "", err
 	}
@@ -154,27 +444,96 @@ func (t *Tree) renderItem(item string, table string) (string, error) {
 
 }
 
-func pickItem(items ...string) string {
-	return items[rand.Intn(len(items))]
+// templateData returns the data for a template with the given arguments added to the
+// tree's values
+func (t *Tree) templateData(args map[string]interface{}) map[string]interface{} {
+	if len(t.values) == 0 && args != nil {
+		return args
+	}
+	data := make(map[string]interface{}, len(t.values)+len(args))
+	for k, v := range t.values {
+		data[k] = v
+	}
+	mergeArgs(data, args)
+	return data
+}
+
+// parseItem parses the templates in an item without rendering them. Items are only parsed
+// once and the returned template is shared, so it must not be executed.
+func (t *Tree) parseItem(item string) (*template.Template, error) {
+	cached := t.templates.get(item)
+	return cached.parsed, cached.err
+}
+
+// treeFuncs returns the functions that the tree adds to templates on the given table
+func (t *Tree) treeFuncs(gen *generation, table string) template.FuncMap {
+	return template.FuncMap{
+		"lookup":      t.getLookup(gen, table),
+		"roll":        t.getRoll(gen),
+		"fudge":       t.getFudge(gen, table),
+		"pick":        t.getPickItem(gen),
+		"chance":      t.getChance(gen),
+		"draw":        t.getDraw(gen, table),
+		"shuffleDeck": t.getShuffleDeck(table),
+		"remaining":   t.getRemaining(table),
+		"norepeat":    t.getNoRepeat(gen, table),
+		"lookupRow":   t.getLookupRow(gen, table),
+		"sticky":      t.getSticky(gen, table),
+		"remember":    t.getRemember(gen),
+		"recall":      t.getRecall(gen),
+		"trusted":     t.getTrusted(gen),
+		"until":       t.getUntil(gen),
+		"untilStep":   t.getUntilStep(gen),
+		// sprig functions that can make long strings are kept to the limit on output
+		"repeat":  gen.repeat,
+		"indent":  gen.indent,
+		"nindent": gen.nindent,
+		// sprig functions that are random use the tree's randomness so a seed gives the
+		// same item every time
+		"randAlpha":    t.getRandString(gen, alphaChars),
+		"randAlphaNum": t.getRandString(gen, alphaChars+numericChars),
+		"randNumeric":  t.getRandString(gen, numericChars),
+		"randAscii":    t.getRandString(gen, asciiChars),
+		"uuidv4":       t.getUUID(),
+		"shuffle":      t.getShuffleString(),
+	}
+}
+
+func (t *Tree) getPickItem(gen *generation) func(...string) (string, error) {
+	return func(items ...string) (string, error) {
+		if len(items) == 0 {
+			return "", ErrNothingToPick
+		}
+		row := t.rand.Intn(len(items))
+		gen.record(&Trace{Function: "pick", Row: &row, Result: items[row]})
+		return items[row], nil
+	}
 }
 
-func chance(chance float32, fallback, original string) string{
-	if rand.Float32() <= chance{
-		return original
+func (t *Tree) getChance(gen *generation) func(float32, string, string) string {
+	return func(chance float32, fallback, original string) string {
+		result := fallback
+		if t.rand.Float32() <= chance {
+			result = original
+		}
+		gen.record(&Trace{Function: "chance", Result: result})
+		return result
 	}
-	return fallback
 }
 
 // getLookup provides a function for retrieving items from other tables.
 // It uses a closure to provide the calling table to allow relative pathing
-func (t *Tree) getLookup(callingTable string) func(string, ...interface{}) (string, error) {
-	return func(item string, rolls ...interface{}) (string, error) {
+func (t *Tree) getLookup(gen *generation, callingTable string) func(string, ...interface{}) (string, error) {
+	return func(item string, args ...interface{}) (string, error) {
 		item = resolvePaths(callingTable, item)
-		// number of times to roll
-		times := parseRollCount(rolls)
+		// number of times to roll, how to roll and what to pass to the table
+		times, mod, named, err := parseLookupArgs(args)
+		if err != nil {
+			return "", err
+		}
 		result := []string{}
 		for x := 1; x <= times; x++ {
-			i, err := t.GetItem(item)
+			i, err := t.getItem(gen, "lookup", item, mod, named)
 			if err != nil {
 				return "", err
 			}
@@ -185,68 +544,405 @@ func (t *Tree) getLookup(callingTable string) func(string, ...interface{}) (stri
 
 }
 
-// roll is a template function for rolling dice on a table
-func (t *Tree) roll(d string) string {
-	result, _, err := dice.Roll(d)
-	if err != nil {
-		return d
+// getSticky provides a function that looks up a table like lookup the first time it's used
+// in a generation. Later uses with the same table and arguments give the same result.
+func (t *Tree) getSticky(gen *generation, callingTable string) func(string, ...interface{}) (string, error) {
+	return func(item string, args ...interface{}) (string, error) {
+		item = resolvePaths(callingTable, item)
+		times, mod, named, err := parseLookupArgs(args)
+		if err != nil {
+			return "", err
+		}
+		// Quoting keeps the table and each argument apart, so "ab" and "a" "b" differ
+		key := fmt.Sprintf("%q %d %#v %#v", item, times, mod, named)
+		if result, found := gen.sticky[key]; found {
+			gen.record(&Trace{Function: "sticky", Table: item, Result: result})
+			return result, nil
+		}
+		result := []string{}
+		for x := 1; x <= times; x++ {
+			i, err := t.getItem(gen, "sticky", item, mod, named)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, i)
+		}
+		gen.sticky[key] = strings.Join(result, ", ")
+		return gen.sticky[key], nil
+	}
+}
+
+// getRemember provides a function that keeps a value for the rest of the generation so
+// that any other template can recall it. The value is returned so it can be printed as
+// it is remembered.
+func (t *Tree) getRemember(gen *generation) func(string, interface{}) interface{} {
+	return func(name string, value interface{}) interface{} {
+		gen.remembered[name] = value
+		gen.record(&Trace{Function: "remember", Name: name, Result: fmt.Sprint(value)})
+		return value
+	}
+}
+
+// getTrusted provides a function that marks markup as safe to use as it is, so that it
+// isn't escaped when items are formatted as html
+func (t *Tree) getTrusted(gen *generation) func(string) string {
+	return func(markup string) string {
+		return t.generationFormatter(gen).Trust(markup)
+	}
+}
+
+// getRecall provides a function that returns a value that was remembered earlier in
+// the generation. Nothing is returned for a name that hasn't been remembered, like an
+// argument that wasn't given, so a table can still be rolled on by itself.
+func (t *Tree) getRecall(gen *generation) func(string) interface{} {
+	return func(name string) interface{} {
+		value, found := gen.remembered[name]
+		step := &Trace{Function: "recall", Name: name}
+		if found {
+			step.Result = fmt.Sprint(value)
+		}
+		gen.record(step)
+		return value
 	}
-	return strconv.Itoa(result.Int())
 }
 
-func (t *Tree) ValidateTables() {
+// getLookupRow provides a function for selecting a whole row from a multi-column table
+func (t *Tree) getLookupRow(gen *generation, callingTable string) func(string, ...interface{}) (Row, error) {
+	return func(table string, args ...interface{}) (Row, error) {
+		table = resolvePaths(callingTable, table)
+		times, mod, named, err := parseLookupArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		if times != 1 || !mod.IsZero() {
+			return nil, errors.New("lookupRow selects a single row and can only be given named arguments")
+		}
+		row, _, err := t.getRow(gen, table, named)
+		return row, err
+	}
+}
+
+// getDraw provides a function for dealing items from a table without replacement.
+// Tables that aren't decks are treated as a deck with one card per item.
+func (t *Tree) getDraw(gen *generation, callingTable string) func(string, ...interface{}) (string, error) {
+	return func(table string, rolls ...interface{}) (string, error) {
+		table = resolvePaths(callingTable, table)
+		tb, name, args, err := t.getTable(table)
+		if err != nil {
+			return "", err
+		}
+		times := parseRollCount(rolls)
+		result := []string{}
+		for x := 1; x <= times; x++ {
+			step, err := gen.enter(&Trace{Function: "draw", Table: name}, t.maxLookupDepth)
+			if err != nil {
+				gen.pop()
+				return "", err
+			}
+			step.Item, err = t.decks.draw(name, tb.Table, t.rand)
+			if err != nil {
+				gen.pop()
+				return "", &TableError{Table: name, Err: err}
+			}
+			step.setArgs(args)
+			step.Result, err = t.renderTableItem(gen, step.Item, newElement(name, step), args)
+			gen.pop()
+			if err != nil {
+				return "", err
+			}
+			result = append(result, step.Result)
+		}
+		return strings.Join(result, ", "), nil
+	}
+}
+
+// getShuffleDeck provides a function that returns all drawn cards to a deck. It isn't
+// called shuffle as that's sprig's function for shuffling a string.
+func (t *Tree) getShuffleDeck(callingTable string) func(string) (string, error) {
+	return func(table string) (string, error) {
+		table = resolvePaths(callingTable, table)
+		tb, name, err := t.GetTable(table)
+		if err != nil {
+			return "", err
+		}
+		t.decks.shuffle(name, tb.Table, t.rand)
+		return "", nil
+	}
+}
+
+// getRemaining provides a function that counts the cards left in a deck
+func (t *Tree) getRemaining(callingTable string) func(string) (int, error) {
+	return func(table string) (int, error) {
+		table = resolvePaths(callingTable, table)
+		tb, name, err := t.GetTable(table)
+		if err != nil {
+			return 0, err
+		}
+		return t.decks.remaining(name, tb.Table), nil
+	}
+}
+
+// maxRepeatAttempts limits how many times norepeat will reroll a table looking for a new result
+const maxRepeatAttempts = 100
+
+// getNoRepeat provides a function that looks up an item that isn't one of the last n
+// results from the same table
+func (t *Tree) getNoRepeat(gen *generation, callingTable string) func(string, int) (string, error) {
+	return func(table string, n int) (string, error) {
+		table = resolvePaths(callingTable, table)
+		tb, name, args, err := t.getTable(table)
+		if err != nil {
+			return "", err
+		}
+		step, err := gen.enter(&Trace{Function: "norepeat", Table: name}, t.maxLookupDepth)
+		defer gen.pop()
+		if err != nil {
+			return "", err
+		}
+		// A table can't avoid repeating more items than it has
+		distinct := len(util.DeDupe(tb.AllItems()))
+		if n >= distinct {
+			n = distinct - 1
+		}
+		var item string
+		for x := 0; x < maxRepeatAttempts; x++ {
+			item, err = t.selectItem(gen, tb.Table, RollModifier{}, step)
+			if err != nil {
+				return "", err
+			}
+			if !t.decks.recent(name, item, n) {
+				break
+			}
+		}
+		t.decks.remember(name, item, n)
+		step.Item = item
+		step.setArgs(args)
+		step.Result, err = t.renderTableItem(gen, item, newElement(name, step), args)
+		return step.Result, err
+	}
+}
+
+// getRoll provides a template function for rolling dice on a table
+func (t *Tree) getRoll(gen *generation) func(string) (string, error) {
+	return func(d string) (string, error) {
+		parsed, err := ParseDice(d)
+		if err != nil {
+			return d, nil
+		}
+		if err := gen.rollDice(parsed.Count()); err != nil {
+			return "", err
+		}
+		result, err := parsed.Roll(t.rand)
+		if err != nil {
+			return d, nil
+		}
+		total := result.Int()
+		gen.record(&Trace{Function: "roll", Dice: d, Roll: &total, Result: strconv.Itoa(total)})
+		return strconv.Itoa(total), nil
+	}
+}
+
+// getUntil provides sprig's until with the generation's limit on ranges
+func (t *Tree) getUntil(gen *generation) func(int) ([]int, error) {
+	return func(count int) ([]int, error) {
+		step := 1
+		if count < 0 {
+			step = -1
+		}
+		return gen.untilStep(0, count, step)
+	}
+}
+
+// getUntilStep provides sprig's untilStep with the generation's limit on ranges
+func (t *Tree) getUntilStep(gen *generation) func(int, int, int) ([]int, error) {
+	return gen.untilStep
+}
+
+// getRandString provides sprig's random string functions, such as randAlpha, picking
+// characters from chars with the tree's randomness within the generation's limit on output
+func (t *Tree) getRandString(gen *generation, chars string) func(int) (string, error) {
+	return func(count int) (string, error) {
+		if err := gen.checkSize(count, 1); err != nil {
+			return "", err
+		}
+		return randString(t.rand, count, chars), nil
+	}
+}
+
+// getUUID provides sprig's uuidv4 with the tree's randomness
+func (t *Tree) getUUID() func() string {
+	return func() string {
+		return randUUID(t.rand)
+	}
+}
+
+// getShuffleString provides sprig's shuffle, which shuffles the characters of a string,
+// with the tree's randomness
+func (t *Tree) getShuffleString() func(string) string {
+	return func(s string) string {
+		runes := []rune(s)
+		t.rand.Shuffle(len(runes), func(i, j int) {
+			runes[i], runes[j] = runes[j], runes[i]
+		})
+		return string(runes)
+	}
+}
+
+// ValidateTables validates every table in the tree and logs the issues that were found
+func (t *Tree) ValidateTables() []Issue {
+	issues := t.Validate()
+	for _, issue := range issues {
+		logger := log.WithField("table", issue.Table)
+		if issue.File != "" {
+			logger = logger.WithField("file", issue.File)
+		}
+		if issue.Line > 0 {
+			logger = logger.WithField("line", issue.Line)
+		}
+		switch issue.Severity {
+		case SeverityError:
+			logger.Error(issue.Message)
+		case SeverityWarning:
+			logger.Warn(issue.Message)
+		default:
+			logger.Debug(issue.Message)
+		}
+	}
+	return issues
+}
+
+// Validate checks every table in the tree and returns the issues that were found, ordered
+// by where they were found. Every item is parsed and rendered once.
+func (t *Tree) Validate() []Issue {
+	issues := append([]Issue{}, *t.loadIssues...)
+	// Rendering can draw from decks and use up random numbers so use a separate
+	// session to leave this tree's state alone
+	session := t.WithSeed(0)
+	ctx := WithLimits(context.Background(), DefaultLimits)
 	t.tables.Walk(func(key string, value interface{}) error {
+		tb, ok := value.(TableNode)
+		if !ok {
+			return nil
+		}
+		add := func(issue Issue) {
+			issue.Table = key
+			issue.File = tb.Source.File
+			issue.Line = tb.Source.Line
+			issues = append(issues, issue)
+		}
 		// Call each table to validate itself
-		if tb, ok := value.(TableNode); ok {
-			tb.Validate()
-
-			// Get all the items and check that they are valid.
-			items := tb.AllItems()
-			for _, item := range items {
-				_, err := t.renderItem(item, key)
-				if err != nil {
-					log.WithField("table", key).Warn(err)
-				}
+		for _, issue := range tb.Validate() {
+			add(issue)
+		}
+
+		// Get all the items and check that they are valid.
+		items := tb.AllItems()
+		if len(items) == 0 {
+			add(newIssue(SeverityWarning, IssueEmptyTable, "table has no items"))
+		}
+		for _, item := range items {
+			if _, err := session.parseItem(item); err != nil {
+				add(newIssue(SeverityError, IssueParseError, "%v", err))
+				continue
+			}
+			// Missing tables are reported once below rather than for every render
+			if t.hasMissingReference(item, key) {
+				continue
+			}
+			if _, err := session.renderItem(newGeneration(ctx), item, key, nil); err != nil {
+				add(newIssue(SeverityError, IssueRenderError, "%v", err))
 			}
 		}
 		return nil
 	})
+	for _, ref := range t.MissingReferences() {
+		issue := newIssue(SeverityError, IssueMissingTable, "%s %q: %s", ref.Function, ref.Target, ref.Error)
+		issue.Table = ref.Table
+		issue.File = ref.File
+		issue.Line = ref.Line
+		issues = append(issues, issue)
+	}
+	for _, cycle := range t.lookupCycles() {
+		issue := newIssue(SeverityWarning, IssueCycle, "lookups lead back to %s: %s", cycle[0], strings.Join(cycle, " -> "))
+		issue.Table = cycle[0]
+		if tb, _, err := t.GetTable(cycle[0]); err == nil {
+			issue.File = tb.Source.File
+			issue.Line = tb.Source.Line
+		}
+		issues = append(issues, issue)
+	}
+	sortIssues(issues)
+	return issues
 }
 
 // fudge performs a lookup on the given table but uses and alternate dice string
-func (t *Tree) getFudge(callingTable string) func(string, string, ...interface{}) (string, error) {
+func (t *Tree) getFudge(gen *generation, callingTable string) func(string, string, ...interface{}) (string, error) {
 	return func(table, dicestr string, rolls ...interface{}) (string, error) {
 		table = resolvePaths(callingTable, table)
-		tb, _,err := t.GetTable(table)
+		tb, _, args, err := t.getTable(table)
 		if err != nil {
 			return "", err
 		}
 		times := parseRollCount(rolls)
-		var newTable = NewRollingTable(dicestr)
-		switch rt := tb.Table.(type) {
-		case *RollingTable:
-			for k, v := range rt.items {
-				newTable.items[k] = v
-			}
-			newTable.dicestr = dicestr
-		// Wonky as items is two different types in these tables
-		case *RandomTable:
-			for k, v := range rt.items {
-				newTable.items[k+1] = v
-			}
-			newTable.dicestr = dicestr
-		}
+		newTable := fudgeTable(tb.Table, dicestr)
 
 		result := []string{}
 		for x := 1; x <= times; x++ {
-			i := newTable.GetItem()
-			item, _ := t.renderItem(i, table)
-			result = append(result, t.formatter.Format(item, table))
+			step, err := gen.enter(&Trace{Function: "fudge", Table: table}, t.maxLookupDepth)
+			if err != nil {
+				gen.pop()
+				return "", err
+			}
+			i, err := t.selectItem(gen, &newTable, RollModifier{}, step)
+			if err != nil {
+				gen.pop()
+				return "", err
+			}
+			step.setArgs(args)
+			item, err := t.renderItem(gen, i, table, args)
+			gen.pop()
+			if err != nil {
+				return "", err
+			}
+			step.Item = i
+			step.Result = t.generationFormatter(gen).Format(item, newElement(table, step))
+			result = append(result, step.Result)
 		}
 		return strings.Join(result, ", "), nil
 	}
 }
 
+// fudgeTable copies the items of a table onto a rolling table that uses the given dice
+func fudgeTable(table Table, dicestr string) RollingTable {
+	var newTable = NewRollingTable(dicestr)
+	switch rt := table.(type) {
+	case *RollingTable:
+		newTable.rows = append(newTable.rows, rt.rows...)
+		newTable.intervals = append(newTable.intervals, rt.intervals...)
+	// Wonky as items is two different types in these tables
+	case *RandomTable:
+		for k, v := range rt.items {
+			newTable.AddRange(v, k+1, k+1)
+		}
+	case *DeckTable:
+		pos := 1
+		for _, card := range rt.Cards() {
+			newTable.AddRange(card, pos, pos)
+			pos++
+		}
+	// Each item covers as many rolls as its weight
+	case *WeightedTable:
+		pos := 1
+		for i, item := range rt.items {
+			if weight := rt.weight(i); weight > 0 {
+				newTable.AddRange(item, pos, pos+weight-1)
+				pos += weight
+			}
+		}
+	}
+	return newTable
+}
+
 func resolvePaths(callingTable, table string) string {
 	// replace the relative path with the full path
 	if strings.HasPrefix(table, "./") {
@@ -0,0 +1,174 @@
+package randomtable
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// WEIGHT_COLUMN_NAME is the header text that marks a column as holding weights
+const WEIGHT_COLUMN_NAME = "weight"
+
+// maxWeight is the largest weight a row can have. Validate reports larger weights, which
+// are picked as if they were maxWeight so the total weight can't overflow.
+const maxWeight = 1000000000
+
+// WeightedTable selects items in proportion to the weight given for each item
+type WeightedTable struct {
+	items   []string
+	weights []int
+	// raw weights that could not be parsed, keyed by the index of the item
+	invalid map[int]string
+	rowSources
+}
+
+func (w *WeightedTable) GetItem(r *rand.Rand) (Result, error) {
+	return w.rollItem(r, RollModifier{})
+}
+
+func (w *WeightedTable) rollItem(r *rand.Rand, mod RollModifier) (Result, error) {
+	total := w.totalWeight()
+	if total <= 0 {
+		return Result{}, ErrEmptyTable
+	}
+	// Modifiers move between the rows that can be picked
+	rows := []int{}
+	for i := range w.weights {
+		if w.weight(i) > 0 {
+			rows = append(rows, i)
+		}
+	}
+	pos := mod.apply(func() int {
+		roll := diceRand{r}.Intn(total)
+		for pos, i := range rows {
+			if roll < w.weight(i) {
+				return pos
+			}
+			roll -= w.weight(i)
+		}
+		return len(rows) - 1
+	})
+	row := rows[clamp(pos, 0, len(rows)-1)]
+	return Result{Item: w.items[row], Row: &row}, nil
+}
+
+// AddItem adds an item with the given weight. Items without a weight are given a weight of 1
+func (w *WeightedTable) AddItem(item string, weight ...int) {
+	wt := 1
+	if len(weight) > 0 {
+		wt = weight[0]
+	}
+	w.items = append(w.items, item)
+	w.weights = append(w.weights, wt)
+}
+
+// AddWeightedItem parses the weight string and adds the item. Weights that can't be
+// parsed are recorded and reported by Validate. The item will never be selected.
+func (w *WeightedTable) AddWeightedItem(item string, weight string) {
+	wt, err := strconv.Atoi(strings.TrimSpace(weight))
+	if err != nil {
+		w.invalid[len(w.items)] = weight
+		wt = 0
+	}
+	w.AddItem(item, wt)
+}
+
+func (w WeightedTable) AllItems() []string {
+	return w.items
+}
+
+// Validate checks that every item has a positive, numeric weight of no more than maxWeight
+func (w *WeightedTable) Validate() []Issue {
+	return w.validateWeights(maxWeight, "%s has a weight of %v, which is over the limit of %d")
+}
+
+// validateWeights checks that every item has a positive, numeric weight. Weights over max
+// are reported with overMax, which is given the item, its weight and max.
+func (w *WeightedTable) validateWeights(max int, overMax string) []Issue {
+	issues := duplicateItems(w.items)
+	for i, item := range w.items {
+		if raw, found := w.invalid[i]; found {
+			issues = append(issues, newIssue(SeverityError, IssueInvalidWeight, "%q is not a valid weight for %s", raw, item))
+			continue
+		}
+		if w.weights[i] == 0 {
+			issues = append(issues, newIssue(SeverityWarning, IssueUnreachable, "%s has a weight of zero and can't be selected", item))
+		}
+		if w.weights[i] < 0 {
+			issues = append(issues, newIssue(SeverityError, IssueInvalidWeight, "%s has a negative weight of %v", item, w.weights[i]))
+		}
+		if w.weights[i] > max {
+			issues = append(issues, newIssue(SeverityError, IssueInvalidWeight, overMax, item, w.weights[i], max))
+		}
+	}
+	if len(w.items) > 0 && w.totalWeight() == 0 {
+		issues = append(issues, newIssue(SeverityWarning, IssueUnreachable, "no items can be selected"))
+	}
+	return issues
+}
+
+func (w WeightedTable) Describe() Description {
+	return Description{Kind: KindWeighted, Rows: w.describeRows()}
+}
+
+// describeRows lists each item with its weight
+func (w WeightedTable) describeRows() []RowDescription {
+	rows := make([]RowDescription, len(w.items))
+	for i, item := range w.items {
+		weight := w.weights[i]
+		rows[i] = RowDescription{Item: item, Weight: &weight, InvalidWeight: w.invalid[i], Source: w.rowSource(i)}
+	}
+	return rows
+}
+
+func (w WeightedTable) rowCount() int {
+	return len(w.items)
+}
+
+// clone copies the table so the copy can have items added without changing this table
+func (w WeightedTable) clone() WeightedTable {
+	c := w
+	c.items = append([]string{}, w.items...)
+	c.weights = append([]int{}, w.weights...)
+	c.invalid = make(map[int]string, len(w.invalid))
+	for i, raw := range w.invalid {
+		c.invalid[i] = raw
+	}
+	c.rowSources = append(rowSources{}, w.rowSources...)
+	return c
+}
+
+// Weights returns the weights for each item, in the same order as AllItems
+func (w WeightedTable) Weights() []int {
+	return w.weights
+}
+
+// weight is the weight the row is picked with, which is 0 for rows that can't be picked
+// and at most maxWeight
+func (w WeightedTable) weight(row int) int {
+	return clamp(w.weights[row], 0, maxWeight)
+}
+
+// totalWeight sums all the weights that can be selected. The total stops at the largest
+// int rather than overflowing.
+func (w WeightedTable) totalWeight() int {
+	total := 0
+	for i := range w.weights {
+		weight := w.weight(i)
+		if total > math.MaxInt-weight {
+			return math.MaxInt
+		}
+		total += weight
+	}
+	return total
+}
+
+func NewWeightedTable() WeightedTable {
+	t := WeightedTable{
+		items:   []string{},
+		weights: []int{},
+		invalid: map[int]string{},
+	}
+	return t
+}
@@ -0,0 +1,61 @@
+package randomtable
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestWeightedTable(t *testing.T) {
+	w := NewWeightedTable()
+	w.AddItem("Hello", 3)
+	w.AddItem("Never", 0)
+	w.AddWeightedItem("Bad", "lots")
+	for x := 0; x < 20; x++ {
+		if result, err := w.GetItem(NewRand(1)); err != nil || result.Item != "Hello" {
+			t.Errorf("Didn't get expected item: %v", err)
+		}
+	}
+	if len(w.AllItems()) != 3 {
+		t.Errorf("Expected 3 items but got %v", w.AllItems())
+	}
+	if raw := w.invalid[2]; raw != "lots" {
+		t.Errorf("Expected invalid weight to be recorded but got %q", raw)
+	}
+}
+
+func TestEmptyWeightedTable(t *testing.T) {
+	w := NewWeightedTable()
+	if _, err := w.GetItem(NewRand(1)); !errors.Is(err, ErrEmptyTable) {
+		t.Errorf("Expected no item from an empty table but got %v", err)
+	}
+}
+
+func TestHugeWeights(t *testing.T) {
+	w := NewWeightedTable()
+	w.AddItem("One", math.MaxInt)
+	w.AddItem("Two", math.MaxInt)
+	r := NewRand(1)
+	seen := map[string]bool{}
+	for x := 0; x < 50; x++ {
+		result, err := w.GetItem(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[result.Item] = true
+	}
+	if !seen["One"] || !seen["Two"] {
+		t.Errorf("Expected both items to be picked as if they had the same weight but got %v", seen)
+	}
+	issues := w.Validate()
+	if len(issues) != 2 || issues[0].Kind != IssueInvalidWeight || issues[0].Severity != SeverityError {
+		t.Errorf("Expected an error for each weight over the limit but got %v", issues)
+	}
+	chances, err := itemChances(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chances["One"] != 0.5 || chances["Two"] != 0.5 {
+		t.Errorf("Expected even odds but got %v", chances)
+	}
+}
@@ -1,22 +1,28 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "github.com/awwithro/makemea/api/v1"
 	"github.com/awwithro/makemea/randomtable"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/justinian/dice"
 	"github.com/slack-go/slack"
 )
 
-// NewServer returns a gin server that will serve items from the given tree
-func NewServer(tree *randomtable.Tree) *gin.Engine {
+// NewServer returns a gin server that will serve items from the given tree. Each request
+// uses the tree that is current when the request starts. Generating an item stops with an
+// error after the timeout, or when it goes over the default limits.
+func NewServer(tree *randomtable.SharedTree, timeout time.Duration) *gin.Engine {
 	e := gin.Default()
+	e.Use(Limit(timeout, randomtable.DefaultLimits))
 	e.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST"},
@@ -28,16 +34,68 @@ func NewServer(tree *randomtable.Tree) *gin.Engine {
 	return e
 }
 
-func AttachHandlers(e *gin.Engine, tree *randomtable.Tree) {
+// Limit gives every request a context that stops generating items after the timeout, if
+// it isn't 0, and that has the given limits
+func Limit(timeout time.Duration, limits randomtable.Limits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := randomtable.WithLimits(c.Request.Context(), limits)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// itemError is the status and kind for an error from generating an item. A table that
+// can't be found is only a 404 when it's the one that was asked for, as a missing table
+// looked up along the way is a problem with the tables rather than the request.
+func itemError(err error) (int, string) {
+	var limitErr *randomtable.LimitError
+	var tableErr *randomtable.TableError
+	if _, ok := err.(*randomtable.NotFoundError); ok {
+		return http.StatusNotFound, "notFound"
+	}
+	switch {
+	case errors.Is(err, randomtable.ErrCannotModify), errors.Is(err, randomtable.ErrNoRows):
+		return http.StatusBadRequest, "badRequest"
+	case errors.As(err, &limitErr):
+		return http.StatusUnprocessableEntity, "limit"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, "timeout"
+	case errors.As(err, &tableErr):
+		return http.StatusInternalServerError, "table"
+	}
+	return http.StatusInternalServerError, "render"
+}
+
+// abortWithError responds with the error as an ErrorResponse
+func abortWithError(c *gin.Context, code int, kind string, err error) {
+	c.AbortWithStatusJSON(code, v1.ErrorResponse{Error: err.Error(), Kind: kind})
+}
+
+// abortWithItemError responds with an error from generating an item
+func abortWithItemError(c *gin.Context, err error) {
+	code, kind := itemError(err)
+	abortWithError(c, code, kind, err)
+}
+
+func AttachHandlers(e *gin.Engine, tree *randomtable.SharedTree) {
 	v1 := e.Group("v1")
 	v1.GET("/items/*path", getFunc(tree))
+	v1.GET("/rows/*path", getRowFunc(tree))
 	v1.GET("/tables/*path", listFunc(tree))
+	v1.GET("/describe/*path", describeFunc(tree))
 	v1.GET("/roll/*roll", rollFunc())
+	v1.GET("/status", statusFunc(tree))
 	e.POST("/slack/events", slashCommandFunc(tree))
 }
 
-func listFunc(tree *randomtable.Tree) func(*gin.Context) {
+func listFunc(shared *randomtable.SharedTree) func(*gin.Context) {
 	return func(c *gin.Context) {
+		tree := shared.Load()
 		path := c.Param("path")
 		path = strings.TrimPrefix(path, "/")
 		tables := tree.ListTables(path, false)
@@ -48,39 +106,156 @@ func listFunc(tree *randomtable.Tree) func(*gin.Context) {
 	}
 }
 
-func getFunc(tree *randomtable.Tree) func(*gin.Context) {
+func getFunc(shared *randomtable.SharedTree) func(*gin.Context) {
 	return func(c *gin.Context) {
+		tree := shared.Load()
 		path := c.Param("path")
 		path = strings.TrimPrefix(path, "/")
-		item, err := tree.GetItem(path)
+		if seed := c.Query("seed"); seed != "" {
+			s, err := strconv.ParseInt(seed, 10, 64)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, "badRequest", fmt.Errorf("seed must be a number: %v", err))
+				return
+			}
+			tree = tree.WithSeed(s)
+		}
+		mod, err := parseModifier(c)
 		if err != nil {
-			c.String(http.StatusNotFound, err.Error())
+			abortWithError(c, http.StatusBadRequest, "badRequest", err)
+			return
+		}
+		tree = tree.WithValues(queryValues(c))
+		item, trace, err := tree.GetItemWithModifier(c.Request.Context(), path, mod)
+		if err != nil {
+			abortWithItemError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, v1.GetItemResponse{
-			Item: item,
+			Item:  item,
+			Trace: trace,
 		})
 	}
 }
+
+// parseModifier reads the bonus, advantage and disadvantage query parameters
+func parseModifier(c *gin.Context) (randomtable.RollModifier, error) {
+	mod := randomtable.RollModifier{}
+	if bonus := c.Query("bonus"); bonus != "" {
+		b, err := strconv.Atoi(bonus)
+		if err != nil {
+			return mod, fmt.Errorf("bonus must be a number: %v", err)
+		}
+		mod.Bonus = b
+	}
+	var err error
+	if mod.Advantage, err = queryFlag(c, "advantage"); err != nil {
+		return mod, err
+	}
+	if mod.Disadvantage, err = queryFlag(c, "disadvantage"); err != nil {
+		return mod, err
+	}
+	return mod, nil
+}
+
+// queryValues reads values for every template from parameters such as set.region=north
+func queryValues(c *gin.Context) map[string]string {
+	values := map[string]string{}
+	for key, value := range c.Request.URL.Query() {
+		if name, found := strings.CutPrefix(key, "set."); found && name != "" {
+			values[name] = value[len(value)-1]
+		}
+	}
+	return values
+}
+
+// queryFlag is true when the parameter is given without a value or with a true value
+func queryFlag(c *gin.Context, name string) (bool, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return false, nil
+	}
+	if value == "" {
+		return true, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false: %v", name, err)
+	}
+	return flag, nil
+}
+
+func getRowFunc(shared *randomtable.SharedTree) func(*gin.Context) {
+	return func(c *gin.Context) {
+		tree := shared.Load()
+		path := c.Param("path")
+		path = strings.TrimPrefix(path, "/")
+		tree = tree.WithValues(queryValues(c))
+		row, columns, err := tree.GetRow(c.Request.Context(), path)
+		if err != nil {
+			abortWithItemError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, v1.GetRowResponse{
+			Row:     row,
+			Columns: columns,
+		})
+	}
+}
+
+// describeFunc gives the kind, dice and rows of a table
+func describeFunc(shared *randomtable.SharedTree) func(*gin.Context) {
+	return func(c *gin.Context) {
+		tree := shared.Load()
+		path := c.Param("path")
+		path = strings.TrimPrefix(path, "/")
+		desc, err := tree.Describe(path)
+		if err != nil {
+			abortWithItemError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, v1.DescribeResponse{
+			Table: desc,
+		})
+	}
+}
+
 func rollFunc() func(*gin.Context) {
 	return func(c *gin.Context) {
 		roll := c.Param("roll")
-		result, _, err := dice.Roll(roll)
+		roll = strings.TrimPrefix(roll, "/")
+		result, err := randomtable.RollDiceContext(c.Request.Context(), roll)
+		var limitErr *randomtable.LimitError
+		if errors.As(err, &limitErr) {
+			abortWithItemError(c, err)
+			return
+		}
 		if err != nil {
-			c.String(http.StatusBadRequest, err.Error())
+			abortWithError(c, http.StatusBadRequest, "badRequest", err)
 			return
 		}
 		c.JSON(http.StatusOK, v1.RollResponse{
 			Result:      result.Int(),
-			Description: result.Description(),
+			Description: result.String(),
+		})
+	}
+}
+
+// statusFunc describes the tables being served and any reload that failed since they were loaded
+func statusFunc(shared *randomtable.SharedTree) func(*gin.Context) {
+	return func(c *gin.Context) {
+		status := shared.Status()
+		c.JSON(http.StatusOK, v1.StatusResponse{
+			Version:      status.Version,
+			Hash:         status.Hash,
+			LoadedAt:     status.LoadedAt,
+			FailedReload: status.FailedReload,
 		})
 	}
 }
 
-func slashCommandFunc(tree *randomtable.Tree) func(*gin.Context) {
+func slashCommandFunc(shared *randomtable.SharedTree) func(*gin.Context) {
 	return func(c *gin.Context) {
-		newTree := tree.WithStringFormatter()
-		tree = &newTree
+		tree := shared.Load().WithStringFormatter()
 		s, err := slack.SlashCommandParse(c.Request)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, SlackResponse{
@@ -123,7 +298,7 @@ func slashCommandFunc(tree *randomtable.Tree) func(*gin.Context) {
 				c.JSON(http.StatusOK, resp)
 				return
 			} else {
-				item, err := tree.GetItem(words[0])
+				item, err := tree.GetItem(c.Request.Context(), words[0])
 				if err != nil {
 					c.JSON(http.StatusOK, SlackResponse{
 						Text:         err.Error(),
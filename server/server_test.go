@@ -0,0 +1,363 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/awwithro/makemea/api/v1"
+	"github.com/awwithro/makemea/randomtable"
+	"github.com/gin-gonic/gin"
+)
+
+const stressTables = `
+# Town
+
+| 1d6 | Name   | Job     |
+| --- | ------ | ------- |
+| 1-3 | Ann    | Smith   |
+| 4-6 | Bob    | Baker   |
+
+| Card  | deck |
+| ----- | ---- |
+| Ace   | 2    |
+| King  | 2    |
+
+| Visitor                                                                 |
+| ----------------------------------------------------------------------- |
+| {{lookup "./name"}} the {{lookup "./job"}} with {{draw "./card"}}       |
+| {{norepeat "./name" 1}} and {{(lookupRow "./job").Name}} {{roll "2d6"}} |
+`
+
+func loadTree(t *testing.T) randomtable.Tree {
+	tree := randomtable.NewTree()
+	md := randomtable.NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(stressTables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	return tree.WithHtmlFormatter()
+}
+
+// TestConcurrentReload serves requests while the tree is being replaced. Run it with
+// -race to check that requests and reloads don't share any unguarded state.
+func TestConcurrentReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SLACK_VERIFICATION_TOKEN", "token")
+	shared := randomtable.NewSharedTree(loadTree(t))
+	e := gin.New()
+	AttachHandlers(e, shared)
+
+	slackForm := url.Values{"token": {"token"}, "command": {"/makemea"}, "text": {"town/visitor"}}.Encode()
+	requests := []func() *http.Request{
+		func() *http.Request { return httptest.NewRequest(http.MethodGet, "/v1/items/town/visitor", nil) },
+		func() *http.Request { return httptest.NewRequest(http.MethodGet, "/v1/items/town/visitor?seed=4", nil) },
+		func() *http.Request { return httptest.NewRequest(http.MethodGet, "/v1/rows/town/name", nil) },
+		func() *http.Request { return httptest.NewRequest(http.MethodGet, "/v1/tables/town", nil) },
+		func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(slackForm))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		},
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				shared.Store(loadTree(t))
+			}
+		}
+	}()
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for x := 0; x < 50; x++ {
+				w := httptest.NewRecorder()
+				req := requests[(r+x)%len(requests)]()
+				e.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Errorf("%s returned %d: %s", req.URL, w.Code, w.Body)
+				}
+				// Slack responses use plain text rather than html
+				if req.URL.Path == "/slack/events" && strings.Contains(w.Body.String(), "RandomElement") {
+					t.Errorf("Expected the slack response to be plain text but got %s", w.Body)
+				}
+			}
+		}(r)
+	}
+	readers.Wait()
+	close(done)
+	wg.Wait()
+
+	// The shared tree still uses the html formatter after slack requests
+	tree := shared.Load()
+	item, err := tree.GetItem(context.Background(), "town/name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(item, "RandomElement") {
+		t.Errorf("Expected the shared tree to keep its formatter but got %s", item)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	shared := randomtable.NewSharedTree(loadTree(t))
+	e := gin.New()
+	AttachHandlers(e, shared)
+	status := func() v1.StatusResponse {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status returned %d: %s", w.Code, w.Body)
+		}
+		var resp v1.StatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := status()
+	if first.Version != 1 || first.Hash == "" || first.FailedReload != nil {
+		t.Errorf("Expected the first tree without a failed reload but got %+v", first)
+	}
+	issue := randomtable.Issue{Severity: randomtable.SeverityError, Kind: randomtable.IssueMissingTable, Table: "town/visitor", Message: "lookup uses town/missing"}
+	shared.Reject(nil, []randomtable.Issue{issue})
+	rejected := status()
+	if rejected.Version != 1 || rejected.Hash != first.Hash {
+		t.Errorf("Expected a rejected reload to keep the first tree but got %+v", rejected)
+	}
+	if rejected.FailedReload == nil || len(rejected.FailedReload.Issues) != 1 || rejected.FailedReload.Issues[0] != issue {
+		t.Errorf("Expected the failed reload to hold %v but got %+v", issue, rejected.FailedReload)
+	}
+
+	shared.Store(loadTree(t))
+	reloaded := status()
+	if reloaded.Version != 2 || reloaded.FailedReload != nil {
+		t.Errorf("Expected the second tree to clear the failed reload but got %+v", reloaded)
+	}
+	// The same tables give the same hash
+	if reloaded.Hash != first.Hash {
+		t.Errorf("Expected hash %s but got %s", first.Hash, reloaded.Hash)
+	}
+}
+
+func TestItemModifiers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	AttachHandlers(e, randomtable.NewSharedTree(loadTree(t).WithStringFormatter()))
+	cases := []struct {
+		query string
+		code  int
+		item  string
+	}{
+		{query: "bonus=10", code: http.StatusOK, item: "Bob"},
+		{query: "bonus=-10&advantage", code: http.StatusOK, item: "Ann"},
+		{query: "bonus=x", code: http.StatusBadRequest},
+		{query: "disadvantage=maybe", code: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items/town/name?"+tc.query, nil))
+		if w.Code != tc.code {
+			t.Errorf("Expected %d for %s but got %d: %s", tc.code, tc.query, w.Code, w.Body)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var resp v1.GetItemResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Item != tc.item {
+			t.Errorf("Expected %s for %s but got %s", tc.item, tc.query, resp.Item)
+		}
+	}
+}
+
+func TestItemValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	tree := randomtable.NewTree()
+	md := randomtable.NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	tables := "# Hex\n\n| Encounter |\n| --- |\n| {{ lookup \"./beast\" }} |\n\n| Beast |\n| --- |\n| tier {{ .tier }} {{ .region }} wolf |\n"
+	if err := md.Convert([]byte(tables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	AttachHandlers(e, randomtable.NewSharedTree(tree))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items/hex/encounter?set.region=north&set.tier=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 but got %d: %s", w.Code, w.Body)
+	}
+	var resp v1.GetItemResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Item != "tier 2 north wolf" {
+		t.Errorf("Expected the values to reach the nested lookup but got %s", resp.Item)
+	}
+}
+
+// TestForgedMarkup sends the markers the html formatter uses for trusted markup as a value
+// and checks that they aren't trusted
+func TestForgedMarkup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	tree := randomtable.NewTree()
+	md := randomtable.NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte("# Hex\n\n| Beast |\n| --- |\n| {{ .name }} |\n"), &buf); err != nil {
+		t.Fatal(err)
+	}
+	AttachHandlers(e, randomtable.NewSharedTree(tree.WithHtmlFormatter()))
+	// U+E003 and U+E004 surround hex encoded trusted markup
+	forged := "\ue003" + "3c7363726970743e616c6572742831293c2f7363726970743e" + "\ue004"
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items/hex/beast?set.name="+url.QueryEscape(forged), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 but got %d: %s", w.Code, w.Body)
+	}
+	var resp v1.GetItemResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(resp.Item, "<script>") {
+		t.Errorf("Expected the forged markup to be escaped but got %s", resp.Item)
+	}
+}
+
+func TestItemLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tree := randomtable.NewTree()
+	md := randomtable.NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	tables := "# Big\n\n| Many |\n| --- |\n| {{ lookup \"./one\" 10 }} |\n\n| One |\n| --- |\n| x |\n"
+	if err := md.Convert([]byte(tables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		timeout time.Duration
+		limits  randomtable.Limits
+		code    int
+	}{
+		{limits: randomtable.Limits{MaxLookups: 5}, code: http.StatusUnprocessableEntity},
+		{timeout: time.Nanosecond, code: http.StatusServiceUnavailable},
+		{timeout: time.Minute, limits: randomtable.DefaultLimits, code: http.StatusOK},
+	}
+	for _, tc := range cases {
+		e := gin.New()
+		e.Use(Limit(tc.timeout, tc.limits))
+		AttachHandlers(e, randomtable.NewSharedTree(tree))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items/big/many", nil))
+		if w.Code != tc.code {
+			t.Errorf("Expected %d with %s and %+v but got %d: %s", tc.code, tc.timeout, tc.limits, w.Code, w.Body)
+		}
+	}
+}
+
+func TestItemErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tree := randomtable.NewTree()
+	md := randomtable.NewMarkdownParser(tree)
+	var buf bytes.Buffer
+	tables := "# Broken\n\n| 1d1 | Gap |\n| --- | --- |\n| 2 | never |\n\n| Lost |\n| --- |\n| {{ lookup \"./missing\" }} |\n\n| Loot | deck |\n| --- | --- |\n| coin | 2 |\n"
+	if err := md.Convert([]byte(tables), &buf); err != nil {
+		t.Fatal(err)
+	}
+	e := gin.New()
+	AttachHandlers(e, randomtable.NewSharedTree(tree))
+	cases := []struct {
+		url  string
+		code int
+		kind string
+	}{
+		{url: "/v1/items/broken/missing", code: http.StatusNotFound, kind: "notFound"},
+		{url: "/v1/items/broken/gap", code: http.StatusInternalServerError, kind: "table"},
+		{url: "/v1/items/broken/lost", code: http.StatusInternalServerError, kind: "render"},
+		{url: "/v1/items/broken/loot?bonus=1", code: http.StatusBadRequest, kind: "badRequest"},
+		{url: "/v1/items/broken/loot?seed=x", code: http.StatusBadRequest, kind: "badRequest"},
+		{url: "/v1/rows/broken/missing", code: http.StatusNotFound, kind: "notFound"},
+		{url: "/v1/roll/lots", code: http.StatusBadRequest, kind: "badRequest"},
+		{url: "/v1/roll/9999999999999d6", code: http.StatusBadRequest, kind: "badRequest"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.url, nil))
+		if w.Code != tc.code {
+			t.Errorf("Expected %d for %s but got %d: %s", tc.code, tc.url, w.Code, w.Body)
+			continue
+		}
+		var resp v1.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: expected a json error but got %s", tc.url, w.Body)
+		}
+		if resp.Kind != tc.kind || resp.Error == "" {
+			t.Errorf("Expected a %s error for %s but got %+v", tc.kind, tc.url, resp)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	AttachHandlers(e, randomtable.NewSharedTree(loadTree(t)))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/describe/town/name", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 but got %d: %s", w.Code, w.Body)
+	}
+	var resp v1.DescribeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Table.Name != "town/name" || len(resp.Table.Rows) == 0 || resp.Table.Rows[0].Source.Line == 0 {
+		t.Errorf("Expected the rows of town/name with their lines but got %+v", resp.Table)
+	}
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/describe/town/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 but got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRollLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(Limit(time.Minute, randomtable.DefaultLimits))
+	AttachHandlers(e, randomtable.NewSharedTree(randomtable.NewTree()))
+	cases := []struct {
+		roll string
+		code int
+	}{
+		{roll: "3d6", code: http.StatusOK},
+		{roll: "20000d6", code: http.StatusUnprocessableEntity},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/roll/"+tc.roll, nil))
+		if w.Code != tc.code {
+			t.Errorf("Expected %d for %s but got %d: %s", tc.code, tc.roll, w.Code, w.Body)
+		}
+	}
+}